@@ -0,0 +1,152 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IssuerReference points at a cert-manager Issuer or ClusterIssuer.
+type IssuerReference struct {
+	// Name of the issuer.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Kind of the issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +kubebuilder:default=ClusterIssuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Group of the issuer.
+	// +kubebuilder:default=cert-manager.io
+	// +optional
+	Group string `json:"group,omitempty"`
+}
+
+// AutomtlsSpec defines the mTLS policy for Services in a namespace.
+type AutomtlsSpec struct {
+	// ServiceSelector selects the Services in this namespace that get mTLS.
+	// If unset, every Service in the namespace is selected.
+	// +optional
+	ServiceSelector *metav1.LabelSelector `json:"serviceSelector,omitempty"`
+
+	// IssuerRef is the issuer used for the Service certificates.
+	// Defaults to the cluster CA issuer managed by the operator.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+
+	// Duration is the lifetime of the Service certificates.
+	// +kubebuilder:default="8760h"
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// RenewBefore is how long before expiry the certificates are renewed. It must be
+	// shorter than the duration. Defaults to a third of the duration, at most 720h.
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+
+	// CertMountPath is where the Service certificate and key are mounted.
+	// +kubebuilder:default="/etc/tls"
+	// +optional
+	CertMountPath string `json:"certMountPath,omitempty"`
+
+	// CAMountPath is where the CA certificate is mounted.
+	// +kubebuilder:default="/etc/ca"
+	// +optional
+	CAMountPath string `json:"caMountPath,omitempty"`
+
+	// AllowedCallers lists the client identities allowed to call the selected
+	// Services, as SPIFFE IDs or "<namespace>/<serviceAccount>". The list is written
+	// to the allowed-callers file mounted next to the certificate, at
+	// <certMountPath>-policy. An empty list allows no caller. When unset no file is mounted.
+	// +optional
+	AllowedCallers []string `json:"allowedCallers,omitempty"`
+}
+
+// Condition types reported for every managed Service.
+const (
+	// ConditionCertificateReady tracks the Ready condition of the Service Certificate.
+	ConditionCertificateReady = "CertificateReady"
+	// ConditionCACopied tracks the copy of the CA certificate into the Service namespace.
+	ConditionCACopied = "CACertificateCopied"
+	// ConditionMounted tracks the certificate mounts on the workloads selected by the Service.
+	ConditionMounted = "CertificatesMounted"
+	// ConditionReady is True when all of the above are True.
+	ConditionReady = "Ready"
+)
+
+// ServiceStatus reports the mTLS state of a single Service. It is also written as
+// JSON to the auto-mtls.kupher.io/status annotation of the Service.
+type ServiceStatus struct {
+	// Name of the Service.
+	Name string `json:"name"`
+
+	// Conditions of the steps that enable mTLS for the Service.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// CertificateNotAfter is the expiry time of the Service certificate.
+	// +optional
+	CertificateNotAfter *metav1.Time `json:"certificateNotAfter,omitempty"`
+
+	// Workloads lists the workloads selected by the Service that carry the
+	// certificate mounts, as Kind/name.
+	// +optional
+	Workloads []string `json:"workloads,omitempty"`
+
+	// LastError is the last error seen while enabling mTLS. It is cleared on success.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// AutomtlsStatus defines the observed state of Automtls.
+type AutomtlsStatus struct {
+	// Services lists the state of every Service selected by this policy.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Services []ServiceStatus `json:"services,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=automtls,scope=Namespaced
+
+// Automtls is the Schema for the automtls API
+type Automtls struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutomtlsSpec   `json:"spec,omitempty"`
+	Status AutomtlsStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AutomtlsList contains a list of Automtls
+type AutomtlsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Automtls `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Automtls{}, &AutomtlsList{})
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AutoMTLSConfigName is the name of the single AutoMTLSConfig the operator reads.
+const AutoMTLSConfigName = "default"
+
+// CASubject is the X.509 subject of the cluster CA certificate.
+type CASubject struct {
+	// CommonName of the CA certificate.
+	// +kubebuilder:default=auto-mtls-cluster-ca
+	// +optional
+	CommonName string `json:"commonName,omitempty"`
+
+	// Organizations of the CA certificate.
+	// +optional
+	Organizations []string `json:"organizations,omitempty"`
+
+	// OrganizationalUnits of the CA certificate.
+	// +optional
+	OrganizationalUnits []string `json:"organizationalUnits,omitempty"`
+
+	// Countries of the CA certificate.
+	// +optional
+	Countries []string `json:"countries,omitempty"`
+
+	// Provinces of the CA certificate.
+	// +optional
+	Provinces []string `json:"provinces,omitempty"`
+
+	// Localities of the CA certificate.
+	// +optional
+	Localities []string `json:"localities,omitempty"`
+}
+
+// PrivateKey configures the private key of issued certificates.
+// +kubebuilder:validation:XValidation:rule="!has(self.size) || (self.algorithm == 'RSA' && self.size in [2048, 3072, 4096]) || (self.algorithm == 'ECDSA' && self.size in [256, 384, 521])",message="size must be 2048, 3072 or 4096 for RSA and 256, 384 or 521 for ECDSA, and is not allowed for Ed25519"
+type PrivateKey struct {
+	// Algorithm of the private key.
+	// +kubebuilder:validation:Enum=RSA;ECDSA;Ed25519
+	// +kubebuilder:default=RSA
+	// +optional
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// Size is the key size in bits for RSA, or the curve size for ECDSA (256 for
+	// P-256, 384 for P-384). Defaults to 2048 for RSA and 256 for ECDSA.
+	// +optional
+	Size int `json:"size,omitempty"`
+
+	// RotationPolicy is Always to generate a new key on every issuance, or Never
+	// to keep reusing the existing key.
+	// +kubebuilder:validation:Enum=Never;Always
+	// +optional
+	RotationPolicy string `json:"rotationPolicy,omitempty"`
+}
+
+// SecretKeyReference points at a key of a Secret.
+type SecretKeyReference struct {
+	// Namespace of the Secret. Defaults to the CA namespace.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key holding the PEM encoded CA certificates.
+	// +kubebuilder:default=ca.crt
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// AutoMTLSConfigSpec defines the cluster PKI the operator bootstraps on top of cert-manager.
+// +kubebuilder:validation:XValidation:rule="!has(self.issuerRef) || has(self.caBundleSecretRef) || (self.issuerRef.kind != 'Issuer' && (!has(self.issuerRef.group) || self.issuerRef.group == 'cert-manager.io'))",message="caBundleSecretRef is required unless issuerRef is a cert-manager ClusterIssuer"
+// +kubebuilder:validation:XValidation:rule="!has(self.caDuration) || !has(self.caRenewBefore) || duration(self.caRenewBefore) < duration(self.caDuration)",message="caRenewBefore must be shorter than caDuration"
+type AutoMTLSConfigSpec struct {
+	// CANamespace is the namespace of the CA Certificate and its Secret. It must be
+	// cert-manager's cluster resource namespace so the CA ClusterIssuer can read the Secret.
+	// +kubebuilder:default=cert-manager
+	// +optional
+	CANamespace string `json:"caNamespace,omitempty"`
+
+	// SelfSignedIssuerName is the name of the self-signed ClusterIssuer that signs the CA.
+	// +kubebuilder:default=auto-mtls-cluster-selfsigned-issuer
+	// +optional
+	SelfSignedIssuerName string `json:"selfSignedIssuerName,omitempty"`
+
+	// CACertificateName is the name of the CA Certificate.
+	// +kubebuilder:default=auto-mtls-cluster-ca-cert
+	// +optional
+	CACertificateName string `json:"caCertificateName,omitempty"`
+
+	// CASecretName is the name of the Secret holding the CA key pair.
+	// +kubebuilder:default=auto-mtls-cluster-ca-cert-secret
+	// +optional
+	CASecretName string `json:"caSecretName,omitempty"`
+
+	// CAIssuerName is the name of the CA ClusterIssuer that signs Service certificates.
+	// +kubebuilder:default=auto-mtls-cluster-ca-issuer
+	// +optional
+	CAIssuerName string `json:"caIssuerName,omitempty"`
+
+	// IssuerRef is an existing cert-manager Issuer or ClusterIssuer, for example one
+	// chaining to a corporate PKI, that signs the Service certificates instead of the
+	// bootstrapped cluster CA. When set, the self-signed issuer, the CA Certificate and
+	// the CA ClusterIssuer are not created. A namespaced Issuer must exist in every
+	// namespace with mTLS Services.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+
+	// CABundleSecretRef is the Secret holding the CA certificates that are trusted in
+	// the namespaces. Defaults to the CA Secret of the bootstrapped cluster CA, or to
+	// the Secret of IssuerRef when it is a CA ClusterIssuer. Required for any other
+	// IssuerRef.
+	// +optional
+	CABundleSecretRef *SecretKeyReference `json:"caBundleSecretRef,omitempty"`
+
+	// TrustBundleSecretName is the name of the Secret in CANamespace holding the
+	// trust bundle copied into the namespaces: the current CA certificate and, during
+	// a rotation, the previous one.
+	// +kubebuilder:default=auto-mtls-ca-bundle
+	// +optional
+	TrustBundleSecretName string `json:"trustBundleSecretName,omitempty"`
+
+	// CADuration is the lifetime of the CA certificate.
+	// +kubebuilder:default="43800h"
+	// +optional
+	CADuration *metav1.Duration `json:"caDuration,omitempty"`
+
+	// CARenewBefore is how long before expiry the CA certificate is renewed.
+	// +kubebuilder:default="2160h"
+	// +optional
+	CARenewBefore *metav1.Duration `json:"caRenewBefore,omitempty"`
+
+	// TrustBundleOverlap is how long the previous CA certificate stays in the trust
+	// bundle after a rotation. It is only removed once the overlap has passed and
+	// every Service certificate has been reissued under the new CA.
+	// +kubebuilder:default="720h"
+	// +optional
+	TrustBundleOverlap *metav1.Duration `json:"trustBundleOverlap,omitempty"`
+
+	// TrustBundlePropagationDelay is how long the operator waits after a trust bundle
+	// holding a new CA has been pushed to every namespace before it reissues the
+	// Service certificates under that CA. It gives kubelet time to refresh the mounted
+	// copies, so no Pod sees a certificate from a CA it does not trust yet.
+	// +kubebuilder:default="5m"
+	// +optional
+	TrustBundlePropagationDelay *metav1.Duration `json:"trustBundlePropagationDelay,omitempty"`
+
+	// CASubject is the subject of the CA certificate.
+	// +optional
+	CASubject CASubject `json:"caSubject,omitempty"`
+
+	// PrivateKey is the private key policy of the CA certificate and the default for
+	// Service certificates. Services can override it with annotations.
+	// +optional
+	PrivateKey *PrivateKey `json:"privateKey,omitempty"`
+
+	// TrustDomain is the trust domain of the SPIFFE IDs written to Service certificates
+	// as URI SANs: spiffe://<trustDomain>/ns/<namespace>/sa/<serviceAccount>.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9._-]+$`
+	// +kubebuilder:validation:MaxLength=255
+	// +kubebuilder:default=cluster.local
+	// +optional
+	TrustDomain string `json:"trustDomain,omitempty"`
+
+	// MountMode selects how certificates reach the Pods. Patch adds the volumes to
+	// the pod template of the workload, which triggers a rollout. Webhook leaves the
+	// workloads alone and injects the volumes into new Pods at admission; the
+	// operator must run with --enable-pod-webhook.
+	// +kubebuilder:validation:Enum=Patch;Webhook
+	// +kubebuilder:default=Patch
+	// +optional
+	MountMode string `json:"mountMode,omitempty"`
+}
+
+// Mount modes of AutoMTLSConfigSpec.MountMode.
+const (
+	// MountModePatch patches the pod template of the selected workload.
+	MountModePatch = "Patch"
+	// MountModeWebhook injects the mounts into Pods through the admission webhook.
+	MountModeWebhook = "Webhook"
+)
+
+// Conditions of AutoMTLSConfigStatus.
+const (
+	// ConditionCAReady reports whether the CA signing the Service certificates is ready.
+	ConditionCAReady = "CAReady"
+	// ConditionCABundleResolved reports whether the CA certificates to trust in the
+	// namespaces could be found.
+	ConditionCABundleResolved = "CABundleResolved"
+)
+
+// AutoMTLSConfigStatus defines the observed state of AutoMTLSConfig.
+type AutoMTLSConfigStatus struct {
+	// Conditions of the cluster PKI.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// CANotAfter is the expiry time of the bootstrapped CA certificate.
+	// +optional
+	CANotAfter *metav1.Time `json:"caNotAfter,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=automtlsconfigs,scope=Cluster
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="the AutoMTLSConfig must be named default"
+
+// AutoMTLSConfig is the cluster-wide operator configuration. Only the object named
+// "default" is read.
+type AutoMTLSConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AutoMTLSConfigSpec   `json:"spec,omitempty"`
+	Status AutoMTLSConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AutoMTLSConfigList contains a list of AutoMTLSConfig
+type AutoMTLSConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AutoMTLSConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AutoMTLSConfig{}, &AutoMTLSConfigList{})
+}
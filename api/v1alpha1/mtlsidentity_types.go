@@ -0,0 +1,139 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadReference names a workload in the namespace of the MTLSIdentity.
+type WorkloadReference struct {
+	// Kind of the workload.
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet;ReplicaSet;Job;CronJob
+	Kind string `json:"kind"`
+
+	// Name of the workload.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// MTLSIdentitySpec defines the workloads that get a client certificate.
+// +kubebuilder:validation:XValidation:rule="has(self.workloadRef) != has(self.selector)",message="exactly one of workloadRef and selector must be set"
+type MTLSIdentitySpec struct {
+	// WorkloadRef names the workload that gets the certificate.
+	// +optional
+	WorkloadRef *WorkloadReference `json:"workloadRef,omitempty"`
+
+	// Selector selects the workloads that get the certificate by the labels of
+	// their pod template.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// IssuerRef is the issuer used for the certificate.
+	// Defaults to the issuer of the Service certificates.
+	// +optional
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+
+	// Duration is the lifetime of the certificate.
+	// +kubebuilder:default="8760h"
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// RenewBefore is how long before expiry the certificate is renewed. It must be
+	// shorter than the duration. Defaults to a third of the duration, at most 720h.
+	// +optional
+	RenewBefore *metav1.Duration `json:"renewBefore,omitempty"`
+
+	// CertMountPath is where the certificate and key are mounted.
+	// +kubebuilder:default="/etc/tls"
+	// +optional
+	CertMountPath string `json:"certMountPath,omitempty"`
+
+	// CAMountPath is where the CA certificate is mounted.
+	// +kubebuilder:default="/etc/ca"
+	// +optional
+	CAMountPath string `json:"caMountPath,omitempty"`
+
+	// Containers receive the mounts. Every container when empty.
+	// +optional
+	Containers []string `json:"containers,omitempty"`
+
+	// RestartOnRotation rolls the selected workloads when the certificate or the CA
+	// bundle changes, for workloads that do not reload the mounted files. A CA
+	// rotation rolls them up to three times.
+	// +optional
+	RestartOnRotation bool `json:"restartOnRotation,omitempty"`
+}
+
+// MTLSIdentityStatus defines the observed state of MTLSIdentity.
+type MTLSIdentityStatus struct {
+	// Conditions of the steps that issue and mount the certificate. They are the
+	// condition types reported for Services.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// SPIFFEID is the identity in the issued certificate, from the service account
+	// the selected workloads run as.
+	// +optional
+	SPIFFEID string `json:"spiffeID,omitempty"`
+
+	// SecretName is the Secret holding the certificate and key.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// CertificateNotAfter is the expiry time of the certificate.
+	// +optional
+	CertificateNotAfter *metav1.Time `json:"certificateNotAfter,omitempty"`
+
+	// Workloads lists the selected workloads that carry the certificate mounts, as Kind/name.
+	// +optional
+	Workloads []string `json:"workloads,omitempty"`
+
+	// LastError is the last error seen while issuing or mounting the certificate.
+	// It is cleared on success.
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=mtlsidentities,scope=Namespaced
+
+// MTLSIdentity issues a client certificate for workloads that have no Service, such
+// as workers, queue consumers and CronJobs.
+type MTLSIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MTLSIdentitySpec   `json:"spec,omitempty"`
+	Status MTLSIdentityStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MTLSIdentityList contains a list of MTLSIdentity
+type MTLSIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MTLSIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MTLSIdentity{}, &MTLSIdentityList{})
+}
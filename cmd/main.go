@@ -39,7 +39,9 @@ import (
 
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
 
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
 	"github.com/kupher-tools/auto-mtls/internal/controller"
+	webhookv1 "github.com/kupher-tools/auto-mtls/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -53,6 +55,8 @@ func init() {
 
 	utilruntime.Must(certmanagerv1.AddToScheme(scheme))
 
+	utilruntime.Must(automtlsv1alpha1.AddToScheme(scheme))
+
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -65,6 +69,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var enablePodWebhook bool
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -83,6 +88,9 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.BoolVar(&enablePodWebhook, "enable-pod-webhook", false,
+		"If set, the Pod mutating webhook that injects the mTLS mounts is served. "+
+			"It requires the webhook certificate and is used when the AutoMTLSConfig mountMode is Webhook.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -205,21 +213,47 @@ func main() {
 	}
 
 	if err := (&controller.CertMgrReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("auto-mtls"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Cert-Mgr")
 		os.Exit(1)
 	}
 
 	if err := (&controller.AutomtlsReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("auto-mtls"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Automtls")
+		os.Exit(1)
+	}
+
+	if err := (&controller.MTLSIdentityReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("auto-mtls"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MTLSIdentity")
+		os.Exit(1)
+	}
+
+	if err := (&controller.CABundleReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Automtls")
+		setupLog.Error(err, "unable to create controller", "controller", "CABundle")
 		os.Exit(1)
 	}
 
+	if enablePodWebhook {
+		if err := webhookv1.SetupPodWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Pod")
+			os.Exit(1)
+		}
+	}
+
 	/*if err := (&controller.DeploymentReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
@@ -254,6 +288,17 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// The operator is only ready once the PKI it hands out certificates from is usable
+	for name, check := range map[string]healthz.Checker{
+		"cert-manager-api": controller.CertManagerAPICheck(mgr.GetRESTMapper()),
+		"ca-issuer":        controller.CAIssuerReadyCheck(mgr.GetClient()),
+		"ca-secret":        controller.CASecretCheck(mgr.GetClient()),
+	} {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", name)
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
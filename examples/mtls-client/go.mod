@@ -1,3 +1,7 @@
 module mtls-client
 
 go 1.24.5
+
+require github.com/kupher-tools/auto-mtls v0.0.0
+
+replace github.com/kupher-tools/auto-mtls => ../..
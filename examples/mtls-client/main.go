@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/kupher-tools/auto-mtls/pkg/mtls"
 )
 
 func main() {
@@ -19,36 +21,26 @@ func main() {
 	}
 	url := fmt.Sprintf("https://%s:8443", serverHost)
 
-	// Load client certificate
-	clientCert, err := tls.LoadX509KeyPair("/etc/tls/tls.crt", "/etc/tls/tls.key")
-	if err != nil {
-		log.Fatalf("failed to load client cert: %v", err)
-	}
-
-	// Load CA certificate to verify server
-	caCert, err := os.ReadFile("/etc/ca/ca.crt")
+	// Load client certificate and CA, renewals are picked up without a restart
+	source, err := mtls.NewSource(mtls.Options{})
 	if err != nil {
-		log.Fatalf("failed to read CA cert: %v", err)
+		log.Fatalf("failed to load mTLS certificates: %v", err)
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
+	go source.Run(context.Background())
 
 	// TLS configuration with verbose handshake logging
-	tlsConfig := &tls.Config{
-		Certificates:       []tls.Certificate{clientCert},
-		RootCAs:            caCertPool,
-		InsecureSkipVerify: false,
-		VerifyPeerCertificate: func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
-			fmt.Println("----- TLS Handshake Info -----")
-			for i, chain := range verifiedChains {
-				fmt.Printf("Chain %d:\n", i)
-				for j, cert := range chain {
-					fmt.Printf("  Cert %d: CN=%s, Issuer=%s\n", j, cert.Subject.CommonName, cert.Issuer.CommonName)
-				}
-			}
-			fmt.Println("-------------------------------")
-			return nil
-		},
+	tlsConfig := source.ClientConfig()
+	verify := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if err := verify(cs); err != nil {
+			return err
+		}
+		fmt.Println("----- TLS Handshake Info -----")
+		for j, cert := range cs.PeerCertificates {
+			fmt.Printf("  Cert %d: CN=%s, SPIFFE ID=%s, Issuer=%s\n", j, cert.Subject.CommonName, mtls.SPIFFEID(cert), cert.Issuer.CommonName)
+		}
+		fmt.Println("-------------------------------")
+		return nil
 	}
 
 	client := &http.Client{
@@ -1,42 +1,45 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+
+	"github.com/kupher-tools/auto-mtls/pkg/mtls"
 )
 
-func main() {
-	// Load server cert and key
-	serverCert, err := tls.LoadX509KeyPair("/etc/tls/tls.crt", "/etc/tls/tls.key")
-	if err != nil {
-		log.Fatalf("failed to load server key pair: %v", err)
-	}
+// policyDir is where auto-mtls mounts the caller policy of the Service. Its file
+// allowed-callers lists the SPIFFE IDs allowed to call the server, one per line,
+// rendered from the allowed-callers annotation of the Service.
+const (
+	policyDir  = "/etc/tls-policy"
+	policyFile = policyDir + "/allowed-callers"
+)
 
-	// Load CA cert to verify clients
-	caCert, err := os.ReadFile("/etc/ca/ca.crt")
+func main() {
+	// Load server cert, key and CA, renewals are picked up without a restart
+	source, err := mtls.NewSource(mtls.Options{})
 	if err != nil {
-		log.Fatalf("failed to read CA cert: %v", err)
-	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-
-	// TLS configuration with client cert verification
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientCAs:    caCertPool,
-		ClientAuth:   tls.RequireAndVerifyClientCert, // enforce mTLS
+		log.Fatalf("failed to load mTLS certificates: %v", err)
 	}
+	go source.Run(context.Background())
 
 	server := &http.Server{
 		Addr:      ":8443",
-		TLSConfig: tlsConfig,
+		TLSConfig: source.ServerConfig(), // enforce mTLS
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			fmt.Println("Client Request from:", r.TLS.PeerCertificates[0].Subject.CommonName)
-			fmt.Fprintf(w, "Hello, %s!", r.TLS.PeerCertificates[0].Subject.CommonName)
+			peer := r.TLS.PeerCertificates[0]
+			fmt.Println("Client Request from:", peer.Subject.CommonName, "SPIFFE ID:", mtls.SPIFFEID(peer))
+			if !callerAllowed(mtls.SPIFFEID(peer)) {
+				fmt.Println("Rejected caller not in", policyFile)
+				http.Error(w, "caller not allowed", http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, "Hello, %s!", peer.Subject.CommonName)
 		}),
 	}
 
@@ -45,3 +48,27 @@ func main() {
 		log.Fatalf("server failed: %v", err)
 	}
 }
+
+// callerAllowed reports whether id is listed in policyFile. The file is read on every
+// call, so policy changes apply without a restart. Every caller is allowed when no
+// policy is mounted, and none when the policy is mounted but its file is missing.
+func callerAllowed(id string) bool {
+	data, err := os.ReadFile(policyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		if _, dirErr := os.Stat(policyDir); dirErr == nil {
+			log.Printf("caller policy %s is missing, denying every caller", policyFile)
+			return false
+		}
+		return true
+	}
+	if err != nil {
+		log.Printf("failed to read caller policy: %v", err)
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == id {
+			return true
+		}
+	}
+	return false
+}
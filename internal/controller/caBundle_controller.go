@@ -0,0 +1,328 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+const (
+	// caBundleLabel marks the namespace copies of the cluster CA.
+	caBundleLabel = "auto-mtls.kupher.io/ca-bundle"
+	// caHashAnnotation records the hash of the CA bundle last written to a copy.
+	caHashAnnotation = "auto-mtls.kupher.io/ca-hash"
+)
+
+// CABundleReconciler publishes the trust bundle built from the CA Secret and keeps
+// the namespace copies of it in sync. When the CA is rotated, the Service certificates
+// are only reissued under the new CA once the bundle trusting both has reached the
+// namespaces, and the previous CA stays in the bundle for the overlap window and until
+// every Service certificate has moved.
+type CABundleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates/status,verbs=get;update;patch
+
+// Reconcile updates the trust bundle Secret from the current CA certificates
+// and pushes it to every namespace copy whose recorded hash differs. The request is
+// ignored, every run syncs all copies.
+func (r *CABundleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to load AutoMTLSConfig")
+		return ctrl.Result{}, err
+	}
+
+	source, key, err := caSourceKey(ctx, r.Client, config)
+	if errors.Is(err, errCASourceUnresolved) {
+		// Reported in the AutoMTLSConfig status, a config or issuer change brings us back
+		log.Info("Cannot sync the trust bundle", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Error(err, "Failed to find the CA certificates to trust")
+		return ctrl.Result{}, err
+	}
+	src := &corev1.Secret{}
+	if err := r.Get(ctx, source, src); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The CA has not been issued yet, its creation triggers another run
+			log.Info("CA secret not found, nothing to sync", "secret", source)
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	caData := caSourceData(src, key)
+	if len(caData) == 0 {
+		log.Info("CA secret has no CA certificate yet, nothing to sync", "secret", source, "key", key)
+		return ctrl.Result{}, nil
+	}
+
+	bundleSecret := &corev1.Secret{}
+	bundleKey := types.NamespacedName{Namespace: config.CANamespace, Name: config.TrustBundleSecretName}
+	if err := r.Get(ctx, bundleKey, bundleSecret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		bundleSecret = nil
+	}
+
+	now := time.Now()
+	bundle, err := nextTrustBundle(caData, bundleSecret, now)
+	if err != nil {
+		// Nothing can be published until the CA Secret is fixed
+		log.Error(err, "Invalid CA certificate in CA secret", "secret", source)
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+
+	caExpiry.Set(float64(earliestExpiry(bundle.current).Unix()))
+
+	// The previous CA is retired once the overlap window has passed and no Service
+	// certificate chains to it any more
+	if bundle.retirable(config.TrustBundleOverlap.Duration, now) {
+		pending, err := r.leavesToReissue(ctx, config, bundle.current)
+		if err != nil {
+			log.Error(err, "Failed to check certificates for the previous CA")
+			return ctrl.Result{}, err
+		}
+		if len(pending) == 0 {
+			log.Info("Retiring previous CA from the trust bundle", "rotatedAt", bundle.rotatedAt)
+			bundle.previous = nil
+			bundle.rotatedAt = time.Time{}
+		}
+	}
+
+	// Every namespace has to trust a new CA before anything is issued under it
+	caBundle := bundle.pem()
+	published, err := r.publishTrustBundle(ctx, bundleKey, bundle, caBundle)
+	if err != nil {
+		log.Error(err, "Failed to update trust bundle", "secret", bundleKey)
+		return ctrl.Result{}, err
+	}
+
+	var copies corev1.SecretList
+	if err := r.List(ctx, &copies, client.MatchingLabels{caBundleLabel: "true"}); err != nil {
+		return ctrl.Result{}, err
+	}
+	hash := caBundleHash(caBundle)
+	// One namespace failing must not keep the others on the old bundle
+	var errs []error
+	for i := range copies.Items {
+		secret := &copies.Items[i]
+		if secret.Name != caCertSecretName || (secret.Annotations[caHashAnnotation] == hash &&
+			bytes.Equal(secret.Data["ca.crt"], caBundle)) {
+			continue
+		}
+		if _, err := syncCACopy(ctx, r.Client, secret.Namespace, caBundle); err != nil {
+			log.Error(err, "Failed to update CA certificate copy", "namespace", secret.Namespace)
+			errs = append(errs, fmt.Errorf("namespace %s: %w", secret.Namespace, err))
+			continue
+		}
+		caSecretCopies.WithLabelValues(secret.Namespace).Inc()
+		log.Info("Updated CA certificate copy", "namespace", secret.Namespace, "hash", hash)
+	}
+	if len(errs) > 0 {
+		// The bundle only counts as published once every copy holds it
+		return ctrl.Result{}, errors.Join(errs...)
+	}
+
+	publishedAt, err := r.markPublished(ctx, published, now)
+	if err != nil {
+		log.Error(err, "Failed to record trust bundle publication", "secret", bundleKey)
+		return ctrl.Result{}, err
+	}
+	if len(bundle.previous) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	// Kubelet only refreshes the mounted copies after its sync period
+	propagated := publishedAt.Add(config.TrustBundlePropagationDelay.Duration)
+	if now.Before(propagated) {
+		log.Info("Waiting for the trust bundle to reach the Pods before reissuing certificates", "until", propagated)
+		return ctrl.Result{RequeueAfter: propagated.Sub(now)}, nil
+	}
+	moved, err := r.reissueLeaves(ctx, config, bundle.current, log)
+	if err != nil {
+		log.Error(err, "Failed to reissue certificates under the new CA")
+		return ctrl.Result{}, err
+	}
+	if !moved {
+		log.Info("Waiting for certificates to be reissued under the new CA")
+		return ctrl.Result{RequeueAfter: time.Minute}, nil
+	}
+	if overlapEnd := bundle.rotatedAt.Add(config.TrustBundleOverlap.Duration); now.Before(overlapEnd) {
+		return ctrl.Result{RequeueAfter: overlapEnd.Sub(now)}, nil
+	}
+	return ctrl.Result{RequeueAfter: time.Minute}, nil
+}
+
+// publishTrustBundle writes caBundle and the rotation state of bundle to the trust
+// bundle Secret and returns it. A changed bundle clears the publication time.
+func (r *CABundleReconciler) publishTrustBundle(ctx context.Context, key types.NamespacedName,
+	bundle trustBundle, caBundle []byte) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	secret.Name = key.Name
+	secret.Namespace = key.Namespace
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[managedByLabel] = managedByValue
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		hash := caBundleHash(caBundle)
+		if secret.Annotations[caHashAnnotation] != hash {
+			delete(secret.Annotations, publishedAtAnnotation)
+		}
+		secret.Annotations[caHashAnnotation] = hash
+		delete(secret.Annotations, rotatedAtAnnotation)
+		if !bundle.rotatedAt.IsZero() {
+			secret.Annotations[rotatedAtAnnotation] = bundle.rotatedAt.UTC().Format(time.RFC3339)
+		}
+		if secret.Type == "" {
+			secret.Type = corev1.SecretTypeOpaque
+		}
+		secret.Data = map[string][]byte{"ca.crt": caBundle}
+		return nil
+	})
+	return secret, err
+}
+
+// markPublished records on the trust bundle Secret when its bundle had been pushed to
+// every namespace copy, unless that is known already, and returns that time.
+func (r *CABundleReconciler) markPublished(ctx context.Context, secret *corev1.Secret, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, secret.Annotations[publishedAtAnnotation]); err == nil {
+		return t, nil
+	}
+	patched := secret.DeepCopy()
+	if patched.Annotations == nil {
+		patched.Annotations = map[string]string{}
+	}
+	patched.Annotations[publishedAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return now, r.Patch(ctx, patched, client.MergeFrom(secret))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CABundleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("cabundle").
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.caBundleRequest)).
+		Watches(&automtlsv1alpha1.AutoMTLSConfig{}, handler.EnqueueRequestsFromMapFunc(r.caBundleRequest)).
+		Watches(&certmanagerv1.ClusterIssuer{}, handler.EnqueueRequestsFromMapFunc(r.caBundleRequest)).
+		Complete(r)
+}
+
+// caBundleRequest maps the CA Secret, the trust bundle, its copies, the AutoMTLSConfig and the
+// external ClusterIssuer to a single request, so concurrent changes collapse into one sync.
+func (r *CABundleReconciler) caBundleRequest(ctx context.Context, obj client.Object) []reconcile.Request {
+	request := []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "ca-bundle"}}}
+	if _, ok := obj.(*automtlsv1alpha1.AutoMTLSConfig); ok || obj.GetLabels()[caBundleLabel] == "true" {
+		return request
+	}
+
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		return nil
+	}
+	if _, ok := obj.(*certmanagerv1.ClusterIssuer); ok {
+		if config.IssuerRef != nil && config.IssuerRef.Kind == "ClusterIssuer" && obj.GetName() == config.IssuerRef.Name {
+			return request
+		}
+		return nil
+	}
+	if obj.GetNamespace() == config.CANamespace && obj.GetName() == config.TrustBundleSecretName {
+		return request
+	}
+	if source, _, err := caSourceKey(ctx, r.Client, config); err == nil && source == client.ObjectKeyFromObject(obj) {
+		return request
+	}
+	return nil
+}
+
+// caBundleHash returns the hash recorded in caHashAnnotation for bundle.
+func caBundleHash(bundle []byte) string {
+	sum := sha256.Sum256(bundle)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// isManagedCACopy reports whether secret is a CA copy created by the operator.
+func isManagedCACopy(secret *corev1.Secret) bool {
+	return secret.Labels[managedByLabel] == managedByValue
+}
+
+// isLegacyCACopy reports whether secret is a CA copy created by an operator version
+// that did not label it. Such copies are adopted by syncCACopy, and are then
+// recognised by isManagedCACopy. Like legacy Certificates, they are never deleted
+// on their shape alone.
+func isLegacyCACopy(secret *corev1.Secret) bool {
+	_, hasCA := secret.Data["ca.crt"]
+	return len(secret.Labels) == 0 && len(secret.OwnerReferences) == 0 &&
+		secret.Type == corev1.SecretTypeOpaque && len(secret.Data) == 1 && hasCA
+}
+
+// syncCACopy creates or updates the CA copy in namespace with caData. It returns
+// errNotManaged if a Secret with the same name was not created by the operator.
+func syncCACopy(ctx context.Context, c client.Client, namespace string, caData []byte) (controllerutil.OperationResult, error) {
+	secret := &corev1.Secret{}
+	secret.Name = caCertSecretName
+	secret.Namespace = namespace
+	return controllerutil.CreateOrUpdate(ctx, c, secret, func() error {
+		if !secret.CreationTimestamp.IsZero() && !isManagedCACopy(secret) && !isLegacyCACopy(secret) {
+			return errNotManaged
+		}
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[managedByLabel] = managedByValue
+		secret.Labels[caBundleLabel] = "true"
+		if secret.Annotations == nil {
+			secret.Annotations = map[string]string{}
+		}
+		secret.Annotations[caHashAnnotation] = caBundleHash(caData)
+		if secret.Type == "" {
+			secret.Type = corev1.SecretTypeOpaque
+		}
+		secret.Data = map[string][]byte{"ca.crt": caData}
+		return nil
+	})
+}
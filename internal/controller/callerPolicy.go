@@ -0,0 +1,148 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// callerPolicyKey is the file in the caller policy ConfigMap listing the allowed
+// callers, one SPIFFE ID per line.
+const callerPolicyKey = "allowed-callers"
+
+// callerPolicyName is the name of the caller policy ConfigMap of the Service svcName,
+// and of its volume.
+func callerPolicyName(svcName string) string {
+	return svcName + "-mtls-policy"
+}
+
+// callerIDs turns the allowed caller entries into sorted SPIFFE IDs. An entry is a
+// SPIFFE ID or "<namespace>/<serviceAccount>" in the given trust domain.
+func callerIDs(trustDomain string, entries []string) ([]string, error) {
+	ids := []string{}
+	for _, entry := range entries {
+		id := entry
+		if !strings.HasPrefix(entry, "spiffe://") {
+			namespace, serviceAccount, ok := strings.Cut(entry, "/")
+			if !ok || namespace == "" || serviceAccount == "" || strings.Contains(serviceAccount, "/") {
+				return nil, fmt.Errorf("invalid allowed caller %q: must be a SPIFFE ID or <namespace>/<serviceAccount>", entry)
+			}
+			id = (&url.URL{
+				Scheme: "spiffe",
+				Host:   trustDomain,
+				Path:   path.Join("/ns", namespace, "sa", serviceAccount),
+			}).String()
+		} else if u, err := url.Parse(entry); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid allowed caller %q: not a SPIFFE ID", entry)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// renderCallerPolicy writes the allowed callers of svc to its caller policy ConfigMap.
+// Kubelet refreshes the mounted file when the ConfigMap changes, so a policy change
+// needs no rollout. A deleted or edited ConfigMap is rendered again. Nothing is done
+// when no allowed callers are set, the ConfigMap is deleted once it is unmounted.
+func (r *AutomtlsReconciler) renderCallerPolicy(ctx context.Context, svc *corev1.Service, settings mtlsSettings,
+	log logr.Logger) error {
+	if settings.allowedCallers == nil {
+		return nil
+	}
+
+	name := callerPolicyName(svc.Name)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: svc.Namespace,
+		},
+	}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		if !cm.CreationTimestamp.IsZero() && !generatedFor(cm, svc) {
+			return errNotManaged
+		}
+		// The Service owns the policy so it is garbage collected with the Service
+		if err := controllerutil.SetControllerReference(svc, cm, r.Scheme); err != nil {
+			return err
+		}
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+		cm.Labels[managedByLabel] = managedByValue
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		cm.Annotations[generatedForAnnotation] = svc.Namespace + "/" + svc.Name
+
+		var policy strings.Builder
+		for _, id := range settings.allowedCallers {
+			policy.WriteString(id + "\n")
+		}
+		cm.Data = map[string]string{callerPolicyKey: policy.String()}
+		return nil
+	})
+	if errors.Is(err, errNotManaged) {
+		// Never take over a ConfigMap someone else created
+		log.Info("ConfigMap exists but was not created for service", "name", name, "service", svc.Name)
+		r.Recorder.Eventf(svc, corev1.EventTypeWarning, "CallerPolicyConflict",
+			"ConfigMap %s exists and was not created by auto-mtls", name)
+		return fmt.Errorf("configmap %s: %w", name, err)
+	}
+	if err != nil {
+		r.Recorder.Eventf(svc, corev1.EventTypeWarning, "CallerPolicyFailed", "Failed to render the caller policy: %v", err)
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		r.Recorder.Eventf(svc, corev1.EventTypeNormal, "CallerPolicyRendered",
+			"Allowed %d caller(s) in %s", len(settings.allowedCallers), name)
+	}
+	log.Info("Reconciled caller policy", "name", name, "service", svc.Name, "operation", op)
+	return nil
+}
+
+// deleteCallerPolicy deletes the caller policy ConfigMap of svc if the operator created it.
+func (r *AutomtlsReconciler) deleteCallerPolicy(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: svc.Namespace, Name: callerPolicyName(svc.Name)}, cm)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !generatedFor(cm, svc) {
+		return nil
+	}
+	if err := r.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	log.Info("Deleted caller policy of service", "name", cm.Name, "service", svc.Name)
+	return nil
+}
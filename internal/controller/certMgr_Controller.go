@@ -18,37 +18,52 @@ package controller
 
 import (
 	"context"
-	"fmt"
-	"time"
+	"errors"
 
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
 )
 
 // AutomtlsReconciler reconciles a Automtls object
 type CertMgrReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=automtls.kupher.io,resources=automtls,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=automtls.kupher.io,resources=automtls/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=automtls.kupher.io,resources=automtls/finalizers,verbs=update
-
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Automtls object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// +kubebuilder:rbac:groups=automtls.kupher.io,resources=automtlsconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=automtls.kupher.io,resources=automtlsconfigs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cert-manager.io,resources=clusterissuers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile bootstraps the cluster PKI described by the AutoMTLSConfig named
+// "default": a self-signed ClusterIssuer, the CA Certificate it signs and the CA
+// ClusterIssuer backed by that certificate. Every run re-applies the config, so
+// edits to the AutoMTLSConfig and drift of the PKI objects are reverted. The Ready
+// condition of the CA is reported in the AutoMTLSConfig status.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
@@ -57,159 +72,324 @@ func (r *CertMgrReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	log.Info("Reconciling Cert Mgr Infra")
 
-	if err := createSelfSignedIssuer(ctx, r.Client); err != nil {
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to load AutoMTLSConfig")
 		return ctrl.Result{}, err
 	}
 
-	if err := createCACert(ctx, r.Client); err != nil {
+	bundleCondition, err := caBundleCondition(ctx, r.Client, config)
+	if err != nil {
 		return ctrl.Result{}, err
 	}
+	if bundleCondition.Status != metav1.ConditionTrue {
+		log.Info("Cannot find the CA certificates to trust", "reason", bundleCondition.Message)
+	}
 
-	if err := createClusterCAIssuer(ctx, r.Client); err != nil {
-		return ctrl.Result{}, err
+	if config.IssuerRef != nil {
+		// Service certificates chain to an existing PKI, there is nothing to bootstrap
+		log.Info("External issuer configured, skipping CA bootstrap", "issuer", config.IssuerRef.Name)
+		condition, err := externalIssuerCondition(ctx, r.Client, config.IssuerRef)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.updateConfigStatus(ctx, nil, condition, bundleCondition)
 	}
 
-	return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
+	if err := createSelfSignedIssuer(ctx, r.Client, config); err != nil {
+		return ctrl.Result{}, r.bootstrapFailed(ctx, err)
+	}
 
-}
+	caCert, op, err := createCACert(ctx, r.Client, config)
+	if err != nil {
+		return ctrl.Result{}, r.bootstrapFailed(ctx, err)
+	}
+	switch op {
+	case controllerutil.OperationResultCreated:
+		r.Recorder.Eventf(caCert, corev1.EventTypeNormal, "Created", "Created the cluster CA Certificate signed by %s", config.SelfSignedIssuerName)
+	case controllerutil.OperationResultUpdated:
+		r.Recorder.Event(caCert, corev1.EventTypeNormal, "Updated", "Applied changes from the AutoMTLSConfig")
+	}
 
-func createSelfSignedIssuer(ctx context.Context, c client.Client) error {
+	if err := createClusterCAIssuer(ctx, r.Client, config); err != nil {
+		return ctrl.Result{}, r.bootstrapFailed(ctx, err)
+	}
 
-	selfSignedIssuer := "auto-mtls-cluster-selfsigned-issuer"
+	// The Certificate watch brings us back when the CA becomes ready
+	condition := metav1.Condition{
+		Type:    automtlsv1alpha1.ConditionCAReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  "Pending",
+		Message: "CA Certificate " + caCert.Name + " has not been issued yet",
+	}
+	for _, cond := range caCert.Status.Conditions {
+		if cond.Type == certmanagerv1.CertificateConditionReady {
+			condition.Status = metav1.ConditionStatus(cond.Status)
+			condition.Reason = cond.Reason
+			condition.Message = cond.Message
+		}
+	}
+	if condition.Status != metav1.ConditionTrue {
+		log.Info("CA Certificate is not ready", "name", caCert.Name, "reason", condition.Reason, "message", condition.Message)
+	}
+	if condition.Status == metav1.ConditionFalse && condition.Reason != "Pending" {
+		// Warn once when the CA breaks, not on every reconcile while it stays broken
+		current, err := r.configConditions(ctx)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if conditionChanges(current, automtlsv1alpha1.ConditionCAReady, false, condition.Reason, condition.Message) {
+			r.Recorder.Eventf(caCert, corev1.EventTypeWarning, "CANotReady", "%s: %s", condition.Reason, condition.Message)
+		}
+	}
+	return ctrl.Result{}, r.updateConfigStatus(ctx, caCert.Status.NotAfter, condition, bundleCondition)
+}
 
-	// Check if it already exists
-	existing := &certmanagerv1.ClusterIssuer{}
-	err := c.Get(ctx, client.ObjectKey{Name: selfSignedIssuer}, existing)
-	if err == nil {
-		ctrl.Log.Info("SelfSigned Issuer already exists, skipping creation", "name", selfSignedIssuer)
-		return nil
+// bootstrapFailed reports err in the CAReady condition and returns it.
+func (r *CertMgrReconciler) bootstrapFailed(ctx context.Context, err error) error {
+	condition := metav1.Condition{
+		Type:    automtlsv1alpha1.ConditionCAReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  "BootstrapFailed",
+		Message: err.Error(),
+	}
+	if statusErr := r.updateConfigStatus(ctx, nil, condition); statusErr != nil {
+		ctrl.Log.Error(statusErr, "Failed to update AutoMTLSConfig status")
 	}
+	return err
+}
 
-	// Define ClusterIssuer object
-	clusterIssuer := &certmanagerv1.ClusterIssuer{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "cert-manager.io/v1",
-			Kind:       "ClusterIssuer",
-		},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: selfSignedIssuer,
-		},
-		Spec: certmanagerv1.IssuerSpec{
-			IssuerConfig: certmanagerv1.IssuerConfig{
-				SelfSigned: &certmanagerv1.SelfSignedIssuer{},
-			},
-		},
+// externalIssuerCondition mirrors the Ready condition of an external ClusterIssuer.
+// The readiness of namespaced Issuers is not tracked.
+func externalIssuerCondition(ctx context.Context, c client.Reader,
+	ref *automtlsv1alpha1.IssuerReference) (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:    automtlsv1alpha1.ConditionCAReady,
+		Status:  metav1.ConditionUnknown,
+		Reason:  "ExternalIssuer",
+		Message: "Certificates are issued by " + ref.Kind + " " + ref.Name,
+	}
+	if ref.Kind != "ClusterIssuer" || (ref.Group != "" && ref.Group != certmanagerv1.SchemeGroupVersion.Group) {
+		return condition, nil
 	}
 
-	// Create if not exists
-	if err := c.Create(ctx, clusterIssuer); err != nil {
-		ctrl.Log.Info("Failed to create SelfSigned Issuer as its already available", "name", clusterIssuer.Name)
-		return err
+	issuer := &certmanagerv1.ClusterIssuer{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, issuer); err != nil {
+		if apierrors.IsNotFound(err) {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "IssuerNotFound"
+			condition.Message = "ClusterIssuer " + ref.Name + " does not exist"
+			return condition, nil
+		}
+		return condition, err
 	}
+	for _, cond := range issuer.Status.Conditions {
+		if cond.Type == certmanagerv1.IssuerConditionReady {
+			condition.Status = metav1.ConditionStatus(cond.Status)
+			condition.Reason = cond.Reason
+			condition.Message = cond.Message
+		}
+	}
+	return condition, nil
+}
 
-	ctrl.Log.Info("SelfSigned Issuer created successfully", "name", clusterIssuer.Name)
-	return nil
+// caBundleCondition reports whether the CA certificates to trust in the namespaces
+// can be found: an external issuer needs caBundleSecretRef unless it is a CA
+// ClusterIssuer.
+func caBundleCondition(ctx context.Context, c client.Reader,
+	config *automtlsv1alpha1.AutoMTLSConfigSpec) (metav1.Condition, error) {
+	source, _, err := caSourceKey(ctx, c, config)
+	if errors.Is(err, errCASourceUnresolved) {
+		return metav1.Condition{
+			Type:    automtlsv1alpha1.ConditionCABundleResolved,
+			Status:  metav1.ConditionFalse,
+			Reason:  "CABundleUnresolved",
+			Message: err.Error(),
+		}, nil
+	}
+	if err != nil {
+		return metav1.Condition{}, err
+	}
+	return metav1.Condition{
+		Type:    automtlsv1alpha1.ConditionCABundleResolved,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Resolved",
+		Message: "The CA certificates in Secret " + source.String() + " are trusted",
+	}, nil
+}
 
+// configConditions returns the conditions recorded on the AutoMTLSConfig, or nil if
+// there is none.
+func (r *CertMgrReconciler) configConditions(ctx context.Context) ([]metav1.Condition, error) {
+	config := &automtlsv1alpha1.AutoMTLSConfig{}
+	if err := r.Get(ctx, client.ObjectKey{Name: automtlsv1alpha1.AutoMTLSConfigName}, config); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	return config.Status.Conditions, nil
 }
 
-func createCACert(ctx context.Context, c client.Client) error {
-	caCertName := "auto-mtls-cluster-ca-cert"
-	caCertNamespace := "cert-manager"
-	caCertSecret := "auto-mtls-cluster-ca-cert-secret"
-	caCertCommonName := "auto-mtls-cluster-ca"
-
-	existing := &certmanagerv1.Certificate{}
-	// Check if it already exists
-	err := c.Get(ctx, client.ObjectKey{Name: caCertName, Namespace: caCertNamespace}, existing)
-	if err == nil {
-		ctrl.Log.Info("ClusterIssuer already exists, skipping creation", "name", caCertName)
+// updateConfigStatus records the CA state on the AutoMTLSConfig, if one exists.
+func (r *CertMgrReconciler) updateConfigStatus(ctx context.Context, notAfter *metav1.Time,
+	conditions ...metav1.Condition) error {
+	config := &automtlsv1alpha1.AutoMTLSConfig{}
+	if err := r.Get(ctx, client.ObjectKey{Name: automtlsv1alpha1.AutoMTLSConfigName}, config); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patched := config.DeepCopy()
+	for _, condition := range conditions {
+		if condition.Reason == "" {
+			condition.Reason = "Unknown"
+		}
+		condition.ObservedGeneration = config.Generation
+		meta.SetStatusCondition(&patched.Status.Conditions, condition)
+	}
+	patched.Status.CANotAfter = notAfter
+	if equality.Semantic.DeepEqual(patched.Status, config.Status) {
 		return nil
 	}
+	return r.Status().Patch(ctx, patched, client.MergeFrom(config))
+}
 
-	// If does not exist, create one
-	caCert := &certmanagerv1.Certificate{
+func createSelfSignedIssuer(ctx context.Context, c client.Client, config *automtlsv1alpha1.AutoMTLSConfigSpec) error {
+
+	clusterIssuer := &certmanagerv1.ClusterIssuer{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      caCertName,
-			Namespace: caCertNamespace,
-		},
-		Spec: certmanagerv1.CertificateSpec{
-			IsCA:       true,
-			SecretName: caCertSecret,
-			CommonName: caCertCommonName,
-			IssuerRef: certmanagermetav1.ObjectReference{
-				Name: "auto-mtls-cluster-selfsigned-issuer",
-				Kind: "ClusterIssuer",
-			},
+			Name: config.SelfSignedIssuerName,
 		},
 	}
 
-	if err := c.Create(ctx, caCert); err != nil {
-		ctrl.Log.Error(err, "Failed to create CA Certificate", "name", caCert.Name)
+	// Create if not exists, otherwise bring the spec back to a self-signed issuer
+	op, err := controllerutil.CreateOrUpdate(ctx, c, clusterIssuer, func() error {
+		clusterIssuer.Spec.IssuerConfig = certmanagerv1.IssuerConfig{
+			SelfSigned: &certmanagerv1.SelfSignedIssuer{},
+		}
+		return nil
+	})
+	if err != nil {
+		ctrl.Log.Error(err, "Failed to reconcile SelfSigned Issuer", "name", clusterIssuer.Name)
 		return err
 	}
+
+	ctrl.Log.Info("SelfSigned Issuer reconciled", "name", clusterIssuer.Name, "operation", op)
 	return nil
+
 }
 
-func createClusterCAIssuer(ctx context.Context, c client.Client) error {
+func createCACert(ctx context.Context, c client.Client,
+	config *automtlsv1alpha1.AutoMTLSConfigSpec) (*certmanagerv1.Certificate, controllerutil.OperationResult, error) {
+	subject := config.CASubject
 
-	caIssuer := "auto-mtls-cluster-ca-issuer"
+	caCert := &certmanagerv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.CACertificateName,
+			Namespace: config.CANamespace,
+		},
+	}
 
-	// Check if it already exists
-	existing := &certmanagerv1.ClusterIssuer{}
-	err := c.Get(ctx, client.ObjectKey{Name: caIssuer}, existing)
-	if err == nil {
-		ctrl.Log.Info("ClusterIssuer already exists, skipping creation", "name", caIssuer)
+	// Create if not exists, otherwise apply changes from the AutoMTLSConfig
+	op, err := controllerutil.CreateOrUpdate(ctx, c, caCert, func() error {
+		caCert.Spec.IsCA = true
+		caCert.Spec.SecretName = config.CASecretName
+		caCert.Spec.Duration = config.CADuration
+		caCert.Spec.RenewBefore = config.CARenewBefore
+		caCert.Spec.CommonName = subject.CommonName
+		caCert.Spec.Subject = nil
+		if len(subject.Organizations)+len(subject.OrganizationalUnits)+len(subject.Countries)+
+			len(subject.Provinces)+len(subject.Localities) > 0 {
+			caCert.Spec.Subject = &certmanagerv1.X509Subject{
+				Organizations:       subject.Organizations,
+				OrganizationalUnits: subject.OrganizationalUnits,
+				Countries:           subject.Countries,
+				Provinces:           subject.Provinces,
+				Localities:          subject.Localities,
+			}
+		}
+		caCert.Spec.IssuerRef = certmanagermetav1.ObjectReference{
+			Name: config.SelfSignedIssuerName,
+			Kind: "ClusterIssuer",
+		}
+		privateKey, err := privateKeySpec(config.PrivateKey)
+		if err != nil {
+			return err
+		}
+		caCert.Spec.PrivateKey = privateKey
 		return nil
+	})
+	if err != nil {
+		ctrl.Log.Error(err, "Failed to reconcile CA Certificate", "name", caCert.Name, "namespace", caCert.Namespace)
+		return nil, op, err
 	}
 
-	// Define ClusterIssuer object
+	ctrl.Log.Info("CA Certificate reconciled", "name", caCert.Name, "namespace", caCert.Namespace, "operation", op)
+	return caCert, op, nil
+}
+
+func createClusterCAIssuer(ctx context.Context, c client.Client, config *automtlsv1alpha1.AutoMTLSConfigSpec) error {
+
 	clusterIssuer := &certmanagerv1.ClusterIssuer{
-		TypeMeta: metav1.TypeMeta{
-			APIVersion: "cert-manager.io/v1",
-			Kind:       "ClusterIssuer",
-		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name: caIssuer,
-		},
-		Spec: certmanagerv1.IssuerSpec{
-			IssuerConfig: certmanagerv1.IssuerConfig{
-				CA: &certmanagerv1.CAIssuer{
-					SecretName: "auto-mtls-cluster-ca-cert-secret",
-				},
-			},
+			Name: config.CAIssuerName,
 		},
 	}
 
-	// Create if not exists
-	if err := c.Create(ctx, clusterIssuer); err != nil {
-		ctrl.Log.Error(err, "Failed to create CA ClusterIssuer", "name", clusterIssuer.Name)
+	// Create if not exists, otherwise point the issuer at the configured CA secret
+	op, err := controllerutil.CreateOrUpdate(ctx, c, clusterIssuer, func() error {
+		clusterIssuer.Spec.IssuerConfig = certmanagerv1.IssuerConfig{
+			CA: &certmanagerv1.CAIssuer{
+				SecretName: config.CASecretName,
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		ctrl.Log.Error(err, "Failed to reconcile CA ClusterIssuer", "name", clusterIssuer.Name)
 		return err
 	}
 
-	ctrl.Log.Info("CA ClusterIssuer created successfully", "name", clusterIssuer.Name)
+	ctrl.Log.Info("CA ClusterIssuer reconciled", "name", clusterIssuer.Name, "operation", op)
 	return nil
 
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Besides the AutoMTLSConfig
+// it watches the PKI objects, so a deleted issuer or an edited CA Certificate is
+// repaired and the CA readiness is reported as soon as it changes. A single event at
+// start bootstraps the PKI when no AutoMTLSConfig exists.
 func (r *CertMgrReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
-		ticker := time.NewTicker(10 * time.Second) // your interval
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ticker.C:
-				fmt.Println("Periodic run of CertMgrReconciler...")
-				// Call your existing Reconcile logic
-				if _, err := r.Reconcile(ctx, ctrl.Request{}); err != nil {
-					fmt.Println("Error in periodic reconcile:", err)
-				}
-			case <-ctx.Done():
-				return nil
-			}
-		}
-	}))
+	bootstrap := make(chan event.GenericEvent, 1)
+	bootstrap <- event.GenericEvent{Object: &automtlsv1alpha1.AutoMTLSConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: automtlsv1alpha1.AutoMTLSConfigName},
+	}}
+	close(bootstrap)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&automtlsv1alpha1.AutoMTLSConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("certmgr").
+		Watches(&certmanagerv1.ClusterIssuer{}, handler.EnqueueRequestsFromMapFunc(r.configForPKIObject)).
+		Watches(&certmanagerv1.Certificate{}, handler.EnqueueRequestsFromMapFunc(r.configForPKIObject)).
+		WatchesRawSource(source.Channel(bootstrap, &handler.EnqueueRequestForObject{})).
+		Complete(r)
+}
 
-	return nil
+// configForPKIObject maps the issuers and the CA Certificate managed or used by the
+// operator to the AutoMTLSConfig.
+func (r *CertMgrReconciler) configForPKIObject(ctx context.Context, obj client.Object) []reconcile.Request {
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		return nil
+	}
+
+	var ours bool
+	switch obj.(type) {
+	case *certmanagerv1.ClusterIssuer:
+		ours = obj.GetName() == config.SelfSignedIssuerName || obj.GetName() == config.CAIssuerName ||
+			(config.IssuerRef != nil && config.IssuerRef.Kind == "ClusterIssuer" && obj.GetName() == config.IssuerRef.Name)
+	case *certmanagerv1.Certificate:
+		ours = obj.GetNamespace() == config.CANamespace && obj.GetName() == config.CACertificateName
+	}
+	if !ours {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: automtlsv1alpha1.AutoMTLSConfigName}}}
 }
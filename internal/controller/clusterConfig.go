@@ -0,0 +1,96 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// loadClusterConfig returns the spec of the AutoMTLSConfig named "default" with every
+// unset field defaulted. A missing config yields the built-in defaults.
+func loadClusterConfig(ctx context.Context, c client.Reader) (*automtlsv1alpha1.AutoMTLSConfigSpec, error) {
+	config := &automtlsv1alpha1.AutoMTLSConfig{}
+	err := c.Get(ctx, client.ObjectKey{Name: automtlsv1alpha1.AutoMTLSConfigName}, config)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	spec := config.Spec.DeepCopy()
+	defaultClusterConfig(spec)
+	return spec, nil
+}
+
+// defaultClusterConfig fills the unset fields of spec. The defaults match the
+// names the operator used before the AutoMTLSConfig existed.
+func defaultClusterConfig(spec *automtlsv1alpha1.AutoMTLSConfigSpec) {
+	if spec.CANamespace == "" {
+		spec.CANamespace = "cert-manager"
+	}
+	if spec.SelfSignedIssuerName == "" {
+		spec.SelfSignedIssuerName = "auto-mtls-cluster-selfsigned-issuer"
+	}
+	if spec.CACertificateName == "" {
+		spec.CACertificateName = "auto-mtls-cluster-ca-cert"
+	}
+	if spec.CASecretName == "" {
+		spec.CASecretName = "auto-mtls-cluster-ca-cert-secret"
+	}
+	if spec.CAIssuerName == "" {
+		spec.CAIssuerName = "auto-mtls-cluster-ca-issuer"
+	}
+	if spec.TrustBundleSecretName == "" {
+		spec.TrustBundleSecretName = "auto-mtls-ca-bundle"
+	}
+	if spec.CADuration == nil {
+		spec.CADuration = &metav1.Duration{Duration: 43800 * time.Hour} // 5 years
+	}
+	if spec.CARenewBefore == nil {
+		spec.CARenewBefore = &metav1.Duration{Duration: 2160 * time.Hour} // 90 days
+	}
+	if spec.TrustBundleOverlap == nil {
+		spec.TrustBundleOverlap = &metav1.Duration{Duration: 720 * time.Hour} // 30 days
+	}
+	if spec.TrustBundlePropagationDelay == nil {
+		spec.TrustBundlePropagationDelay = &metav1.Duration{Duration: 5 * time.Minute}
+	}
+	if spec.CASubject.CommonName == "" {
+		spec.CASubject.CommonName = "auto-mtls-cluster-ca"
+	}
+	if spec.TrustDomain == "" {
+		spec.TrustDomain = "cluster.local"
+	}
+	if spec.MountMode == "" {
+		spec.MountMode = automtlsv1alpha1.MountModePatch
+	}
+}
+
+// serviceIssuerRef returns the issuer that signs Service certificates by default: the
+// external issuer when one is configured, otherwise the cluster CA issuer.
+func serviceIssuerRef(config *automtlsv1alpha1.AutoMTLSConfigSpec) certmanagermetav1.ObjectReference {
+	if ref := config.IssuerRef; ref != nil {
+		return certmanagermetav1.ObjectReference{Name: ref.Name, Kind: ref.Kind, Group: ref.Group}
+	}
+	return certmanagermetav1.ObjectReference{Name: config.CAIssuerName, Kind: "ClusterIssuer"}
+}
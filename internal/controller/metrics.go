@@ -0,0 +1,127 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// metricsNamespace prefixes every metric of the operator.
+const metricsNamespace = "automtls"
+
+var (
+	certificatesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "certificates_created_total",
+		Help:      "Number of Service Certificates created.",
+	}, []string{"namespace"})
+
+	caSecretCopies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "ca_secret_copies_total",
+		Help:      "Number of writes of the CA certificate copy into a namespace.",
+	}, []string{"namespace"})
+
+	workloadPatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "workload_patches_total",
+		Help:      "Number of pod template patches adding or removing certificate mounts.",
+	}, []string{"namespace"})
+
+	reconcileFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "failures_total",
+		Help:      "Number of failed attempts to enable or tear down mTLS for a Service.",
+	}, []string{"namespace"})
+
+	caExpiry = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "ca_expiry_timestamp_seconds",
+		Help:      "Expiry time of the current CA certificate as a Unix timestamp.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(certificatesCreated, caSecretCopies, workloadPatches, reconcileFailures, caExpiry,
+		managedState)
+}
+
+var (
+	certificateExpiryDesc = prometheus.NewDesc(metricsNamespace+"_certificate_expiry_timestamp_seconds",
+		"Expiry time of the certificate of a managed Service as a Unix timestamp.",
+		[]string{"namespace", "service"}, nil)
+	managedServicesDesc = prometheus.NewDesc(metricsNamespace+"_managed_services",
+		"Number of Services with mTLS managed by the operator.",
+		[]string{"namespace"}, nil)
+	managedWorkloadsDesc = prometheus.NewDesc(metricsNamespace+"_managed_workloads",
+		"Number of workloads carrying certificate mounts of a managed Service.",
+		[]string{"namespace"}, nil)
+)
+
+// managedStateCollector reports the state of the managed Services at scrape time,
+// from the status recorded on them, so deleted Services never leave stale series.
+type managedStateCollector struct {
+	// client is set by AutomtlsReconciler.SetupWithManager, nothing is reported before.
+	client client.Reader
+}
+
+// managedState is the registered managedStateCollector.
+var managedState = &managedStateCollector{}
+
+// Describe implements prometheus.Collector.
+func (c *managedStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- certificateExpiryDesc
+	ch <- managedServicesDesc
+	ch <- managedWorkloadsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *managedStateCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.client == nil {
+		return
+	}
+	var svcList corev1.ServiceList
+	if err := c.client.List(context.Background(), &svcList); err != nil {
+		return
+	}
+
+	services := map[string]int{}
+	workloads := map[string]int{}
+	for i := range svcList.Items {
+		svc := &svcList.Items[i]
+		if !controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+			continue
+		}
+		status := serviceStatusFor(svc)
+		services[svc.Namespace]++
+		workloads[svc.Namespace] += len(status.Workloads)
+		if status.CertificateNotAfter != nil {
+			ch <- prometheus.MustNewConstMetric(certificateExpiryDesc, prometheus.GaugeValue,
+				float64(status.CertificateNotAfter.Unix()), svc.Namespace, svc.Name)
+		}
+	}
+	for namespace, n := range services {
+		ch <- prometheus.MustNewConstMetric(managedServicesDesc, prometheus.GaugeValue, float64(n), namespace)
+		ch <- prometheus.MustNewConstMetric(managedWorkloadsDesc, prometheus.GaugeValue, float64(workloads[namespace]), namespace)
+	}
+}
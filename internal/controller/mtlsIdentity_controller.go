@@ -0,0 +1,679 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// identityFinalizer keeps an MTLSIdentity around until the operator has removed the
+// mounts and objects it created for it.
+const identityFinalizer = "auto-mtls.kupher.io/cleanup"
+
+// identityCertName is the name of the Certificate issued for the MTLSIdentity name.
+func identityCertName(name string) string {
+	return name + "-identity-cert"
+}
+
+// identitySecretName is the name of the Secret, and of the volume, holding the
+// certificate of the MTLSIdentity name.
+func identitySecretName(name string) string {
+	return identityCertName(name) + "-tls"
+}
+
+// identityGeneratedFor is the generated-for annotation value of the objects created
+// for identity. It never matches a Service.
+func identityGeneratedFor(identity *automtlsv1alpha1.MTLSIdentity) string {
+	return "MTLSIdentity/" + identity.Namespace + "/" + identity.Name
+}
+
+// identityMounts returns the certificate and CA mounts for identity.
+func identityMounts(identity *automtlsv1alpha1.MTLSIdentity, settings mtlsSettings) []secretMount {
+	return []secretMount{
+		{
+			volumeName: identitySecretName(identity.Name),
+			secretName: identitySecretName(identity.Name),
+			mountPath:  settings.certMountPath,
+		},
+		{
+			volumeName: caCertSecretName,
+			secretName: caCertSecretName,
+			mountPath:  settings.caMountPath,
+		},
+	}
+}
+
+// identitySettings merges the MTLSIdentity spec over the defaults derived from the
+// cluster config. Identities always get a client certificate.
+func identitySettings(config *automtlsv1alpha1.AutoMTLSConfigSpec,
+	identity *automtlsv1alpha1.MTLSIdentity) (mtlsSettings, error) {
+	settings := defaultMTLSSettings(config)
+	settings.mode = modeClient
+
+	spec := identity.Spec
+	if spec.IssuerRef != nil {
+		settings.issuerRef = certmanagermetav1.ObjectReference{
+			Name:  spec.IssuerRef.Name,
+			Kind:  spec.IssuerRef.Kind,
+			Group: spec.IssuerRef.Group,
+		}
+	}
+	if spec.Duration != nil {
+		settings.duration = spec.Duration.Duration
+	}
+	if spec.RenewBefore != nil {
+		settings.renewBefore = spec.RenewBefore.Duration
+	}
+	if spec.CertMountPath != "" {
+		settings.certMountPath = spec.CertMountPath
+	}
+	if spec.CAMountPath != "" {
+		settings.caMountPath = spec.CAMountPath
+	}
+	settings.containers = spec.Containers
+	settings.restartOnRotation = spec.RestartOnRotation
+
+	if spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(spec.Selector); err != nil {
+			return settings, fmt.Errorf("invalid selector: %w", err)
+		}
+	}
+
+	var err error
+	if settings.privateKey, err = privateKeySpec(config.PrivateKey); err != nil {
+		return settings, err
+	}
+	if err := validateMTLSSettings(settings); err != nil {
+		return settings, err
+	}
+	if settings.renewBefore == 0 {
+		settings.renewBefore = defaultRenewBefore(settings.duration)
+	}
+	return settings, nil
+}
+
+// identitySelects reports whether identity selects the workload w.
+func identitySelects(identity *automtlsv1alpha1.MTLSIdentity, w workload) bool {
+	if ref := identity.Spec.WorkloadRef; ref != nil {
+		return w.kind == ref.Kind && w.GetName() == ref.Name
+	}
+	selector := identityLabelSelector(identity)
+	return selector != nil && selector.Matches(labels.Set(podTemplate(w.Object).Labels))
+}
+
+// identityLabelSelector returns the label selector of identity, or nil when it has
+// none. An empty selector selects nothing, the same as a Service without selector.
+func identityLabelSelector(identity *automtlsv1alpha1.MTLSIdentity) labels.Selector {
+	if identity.Spec.Selector == nil {
+		return nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(identity.Spec.Selector)
+	if err != nil || selector.Empty() {
+		return nil
+	}
+	return selector
+}
+
+// MTLSIdentityReconciler reconciles a MTLSIdentity object
+type MTLSIdentityReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=automtls.kupher.io,resources=mtlsidentities,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=automtls.kupher.io,resources=mtlsidentities/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=automtls.kupher.io,resources=mtlsidentities/finalizers,verbs=update
+
+// Reconcile issues a client certificate for the workloads selected by an MTLSIdentity,
+// copies the CA into its namespace and mounts both into the workloads, the same way
+// it is done for Services. The SPIFFE ID in the certificate and the mounted
+// workloads are reported in the MTLSIdentity status.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
+func (r *MTLSIdentityReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	log.Info("Reconciling mTLS identity", "name", req.Name, "namespace", req.Namespace)
+	identity := &automtlsv1alpha1.MTLSIdentity{}
+	if err := r.Get(ctx, req.NamespacedName, identity); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !identity.DeletionTimestamp.IsZero() {
+		// Identity is being deleted → undo everything done for it
+		if err := r.teardownIdentity(ctx, identity, log); err != nil {
+			log.Error(err, "Failed to clean up after deleted identity", "identity", identity.Name)
+			r.Recorder.Eventf(identity, corev1.EventTypeWarning, "CleanupFailed", "Failed to clean up mTLS: %v", err)
+			reconcileFailures.WithLabelValues(identity.Namespace).Inc()
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to load AutoMTLSConfig")
+		return ctrl.Result{}, err
+	}
+
+	status := identity.Status.DeepCopy()
+	settings, err := identitySettings(config, identity)
+	if err != nil {
+		// The spec needs fixing by the user, retrying won't help
+		log.Error(err, "Invalid mTLS settings for identity", "identity", identity.Name)
+		r.Recorder.Event(identity, corev1.EventTypeWarning, "InvalidSettings", err.Error())
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "InvalidSettings", err.Error())
+		status.LastError = err.Error()
+		if statusErr := r.updateIdentityStatus(ctx, identity, status); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+
+	if !controllerutil.ContainsFinalizer(identity, identityFinalizer) {
+		patched := identity.DeepCopy()
+		controllerutil.AddFinalizer(patched, identityFinalizer)
+		err := r.Patch(ctx, patched, client.MergeFromWithOptions(identity, client.MergeFromWithOptimisticLock{}))
+		if err != nil {
+			log.Error(err, "Failed to add cleanup finalizer to identity", "identity", identity.Name)
+			return ctrl.Result{}, err
+		}
+		patched.DeepCopyInto(identity)
+	}
+
+	err = r.enableIdentity(ctx, identity, settings, status, log)
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if statusErr := r.updateIdentityStatus(ctx, identity, status); statusErr != nil {
+		log.Error(statusErr, "Failed to update status of identity", "identity", identity.Name)
+		if err == nil {
+			return ctrl.Result{}, statusErr
+		}
+	}
+	if err != nil {
+		log.Error(err, "Failed to enable mTLS for identity", "identity", identity.Name)
+		reconcileFailures.WithLabelValues(identity.Namespace).Inc()
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// enableIdentity issues the certificate of identity, copies the CA and mounts both
+// into the selected workloads.
+func (r *MTLSIdentityReconciler) enableIdentity(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	settings mtlsSettings, status *automtlsv1alpha1.MTLSIdentityStatus, log logr.Logger) error {
+	workloads, err := r.workloadsForIdentity(ctx, identity)
+	if err != nil {
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
+		return err
+	}
+
+	if err := r.createIdentityCert(ctx, identity, settings, workloads, status, log); err != nil {
+		return err
+	}
+	if err := copyCA(ctx, r.Client, r.Recorder, identity, settings, &status.Conditions, log); err != nil {
+		return err
+	}
+
+	services, identities, err := mtlsUsers(ctx, r.Client, identity.Namespace)
+	if err != nil {
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
+		return err
+	}
+	others := slices.DeleteFunc(identities, func(other automtlsv1alpha1.MTLSIdentity) bool {
+		return other.Name == identity.Name
+	})
+	// Workloads the identity no longer selects lose its mounts
+	if err := r.unmountIdentity(ctx, identity, workloads, services, others, log); err != nil {
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
+		return err
+	}
+	return r.mountIdentity(ctx, identity, settings, workloads, certVolumes(services, others), status, log)
+}
+
+// workloadsForIdentity returns the workloads in the namespace of identity it selects.
+func (r *MTLSIdentityReconciler) workloadsForIdentity(ctx context.Context,
+	identity *automtlsv1alpha1.MTLSIdentity) ([]workload, error) {
+	workloads, err := listWorkloads(ctx, r.Client, identity.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var matched []workload
+	for _, w := range workloads {
+		if identitySelects(identity, w) {
+			matched = append(matched, w)
+		}
+	}
+	return matched, nil
+}
+
+// createIdentityCert creates or updates the client Certificate of identity. It carries
+// the SPIFFE ID of the service account the workloads run as.
+func (r *MTLSIdentityReconciler) createIdentityCert(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	settings mtlsSettings, workloads []workload, status *automtlsv1alpha1.MTLSIdentityStatus, log logr.Logger) error {
+	certName := identityCertName(identity.Name)
+	id, err := spiffeID(settings.trustDomain, identity.Namespace, workloads)
+	if err != nil {
+		// Retrying won't help until the workloads agree on a service account
+		log.Info("Refusing to issue certificate for several identities", "identity", identity.Name, "reason", err.Error())
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "AmbiguousIdentity",
+			"The selected workloads must run as a single service account: "+err.Error())
+		return reconcile.TerminalError(err)
+	}
+	var uris []string
+	if id != "" {
+		uris = []string{id}
+	}
+
+	cert := &certmanagerv1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      certName,
+			Namespace: identity.Namespace,
+		},
+	}
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cert, func() error {
+		if !cert.CreationTimestamp.IsZero() && !metav1.IsControlledBy(cert, identity) {
+			return errNotManaged
+		}
+		if err := controllerutil.SetControllerReference(identity, cert, r.Scheme); err != nil {
+			return err
+		}
+		if cert.Annotations == nil {
+			cert.Annotations = map[string]string{}
+		}
+		cert.Annotations[generatedForAnnotation] = identityGeneratedFor(identity)
+
+		cert.Spec.SecretName = identitySecretName(identity.Name)
+		cert.Spec.Duration = &metav1.Duration{Duration: settings.duration}
+		cert.Spec.RenewBefore = &metav1.Duration{Duration: settings.renewBefore}
+		cert.Spec.CommonName = identity.Name + "." + identity.Namespace
+		cert.Spec.DNSNames = nil
+		cert.Spec.URIs = uris
+		cert.Spec.Usages = certificateUsages(settings.mode)
+		cert.Spec.IssuerRef = settings.issuerRef
+		cert.Spec.PrivateKey = settings.privateKey
+		cert.Spec.SecretTemplate = &certmanagerv1.CertificateSecretTemplate{
+			Annotations: map[string]string{
+				generatedForAnnotation: identityGeneratedFor(identity),
+			},
+		}
+		return nil
+	})
+	if errors.Is(err, errNotManaged) {
+		// Never take over a certificate someone else created
+		log.Info("Certificate exists but was not created for identity", "name", certName, "identity", identity.Name)
+		r.Recorder.Eventf(identity, corev1.EventTypeWarning, "CertificateConflict",
+			"Certificate %s exists and was not created by auto-mtls", certName)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "Conflict",
+			"Certificate "+certName+" exists and was not created by auto-mtls")
+		return fmt.Errorf("certificate %s: %w", certName, err)
+	}
+	if err != nil {
+		log.Error(err, "Failed to create certificate", "name", certName, "namespace", identity.Namespace)
+		r.Recorder.Eventf(identity, corev1.EventTypeWarning, "CertificateFailed", "Failed to create Certificate %s: %v", certName, err)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "CreateFailed", err.Error())
+		return err
+	}
+	switch op {
+	case controllerutil.OperationResultCreated:
+		certificatesCreated.WithLabelValues(identity.Namespace).Inc()
+		r.Recorder.Eventf(identity, corev1.EventTypeNormal, "CertificateCreated", "Created Certificate %s", certName)
+	case controllerutil.OperationResultUpdated:
+		r.Recorder.Eventf(identity, corev1.EventTypeNormal, "CertificateUpdated", "Updated Certificate %s", certName)
+	}
+	log.Info("Reconciled certificate", "name", certName, "namespace", identity.Namespace, "operation", op)
+
+	status.SPIFFEID = id
+	status.SecretName = cert.Spec.SecretName
+	status.CertificateNotAfter = cert.Status.NotAfter
+	setCertificateCondition(&status.Conditions, cert)
+	return nil
+}
+
+// mountIdentity mounts the certificate and CA of identity into the workloads, or
+// leaves that to the Pod webhook in Webhook mount mode. others are the certificate
+// volumes sharing the CA volume, see applyMounts.
+func (r *MTLSIdentityReconciler) mountIdentity(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	settings mtlsSettings, workloads []workload, others []string, status *automtlsv1alpha1.MTLSIdentityStatus,
+	log logr.Logger) error {
+	status.Workloads = nil
+	if len(workloads) == 0 {
+		log.Info("No workload found for identity", "identity", identity.Name)
+		message := "No workload matches the identity"
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "NoWorkload", message) {
+			r.Recorder.Event(identity, corev1.EventTypeWarning, "NoWorkload", message)
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "NoWorkload", message)
+		return nil
+	}
+
+	annotations, err := secretHashAnnotations(ctx, r.Client, identity.Namespace, identityHashAnnotation(identity.Name),
+		identitySecretName(identity.Name), settings)
+	if err != nil {
+		log.Error(err, "Failed to hash mounted secrets for identity", "identity", identity.Name)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
+		return err
+	}
+	source := "MTLSIdentity " + identity.Name
+
+	if settings.mountMode == automtlsv1alpha1.MountModeWebhook {
+		// The Pod webhook adds the mounts when the Pods of the workloads are created,
+		// only the secret hash goes on the pod templates
+		if err := stampSecretHash(ctx, r.Client, r.Recorder, identity, source, workloads, annotations, log); err != nil {
+			setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
+			return err
+		}
+		for _, w := range workloads {
+			status.Workloads = append(status.Workloads, w.String())
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, true, "Webhook",
+			"New Pods of "+strings.Join(status.Workloads, ", ")+" get the mounts from the admission webhook")
+		return nil
+	}
+
+	// Patch every workload, one failing must not keep the others from getting certificates
+	var immutable, conflicts []workload
+	var conflictErrs []string
+	var errs []error
+	for _, w := range workloads {
+		stamp := annotations
+		if w.kind == "Job" {
+			// The Pods of a Job are not rolled
+			stamp = nil
+		}
+		rotated := hashRotated(w, stamp)
+		patched, err := mountSecrets(ctx, r.Client, w, identityMounts(identity, settings), settings.containers, others, stamp)
+		if errors.Is(err, errMountConflict) {
+			// Patching would take the mounts over from a Service or another identity
+			log.Info("Cannot mount certificates into workload", "workload", w.String(), "identity", identity.Name, "reason", err.Error())
+			conflicts = append(conflicts, w)
+			conflictErrs = append(conflictErrs, err.Error())
+			continue
+		}
+		if errors.Is(err, errImmutableTemplate) {
+			// Retrying won't help, the Job has to be recreated with the mounts
+			log.Info("Cannot mount certificates into workload with immutable pod template", "workload", w.String(), "identity", identity.Name)
+			immutable = append(immutable, w)
+			continue
+		}
+		if err != nil {
+			log.Error(err, "Failed to patch workload with client certificate", "workload", w.String(), "identity", identity.Name)
+			r.Recorder.Eventf(identity, corev1.EventTypeWarning, "PatchFailed", "Failed to mount certificates: %v", err)
+			r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "PatchFailed",
+				"Failed to mount the certificate of MTLSIdentity %s: %v", identity.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if patched {
+			r.Recorder.Eventf(identity, corev1.EventTypeNormal, "MountsPatched", "Mounted certificates into %s", w.String())
+			r.Recorder.Eventf(w.Object, corev1.EventTypeNormal, "MountsPatched",
+				"Mounted the certificate of MTLSIdentity %s at %s and %s", identity.Name, settings.certMountPath, settings.caMountPath)
+		}
+		if patched && rotated {
+			recordRollout(r.Recorder, identity, source, w)
+		}
+		log.Info("Mounted client certificate into workload", "workload", w.String(), "identity", identity.Name)
+		status.Workloads = append(status.Workloads, w.String())
+	}
+
+	switch {
+	case len(errs) > 0:
+		err := errors.Join(errs...)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
+		return err
+	case len(conflicts) > 0:
+		message := strings.Join(conflictErrs, "; ")
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "MountConflict", message) {
+			for i, w := range conflicts {
+				r.Recorder.Eventf(identity, corev1.EventTypeWarning, "MountConflict", "Cannot mount certificates: %s", conflictErrs[i])
+				r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "MountConflict",
+					"Cannot mount the certificate of MTLSIdentity %s: %s", identity.Name, conflictErrs[i])
+			}
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "MountConflict", message)
+	case len(immutable) > 0:
+		message := "The pod template of " + workloadNames(immutable) + " cannot be patched"
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "TemplateImmutable", message) {
+			for _, w := range immutable {
+				r.Recorder.Eventf(identity, corev1.EventTypeWarning, "TemplateImmutable",
+					"Cannot mount certificates into %s, its pod template is immutable", w.String())
+				r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "TemplateImmutable",
+					"Cannot mount the certificate of MTLSIdentity %s, the pod template is immutable", identity.Name)
+			}
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "TemplateImmutable", message)
+	default:
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, true, "Mounted",
+			"Mounted into "+strings.Join(status.Workloads, ", "))
+	}
+	return nil
+}
+
+// updateIdentityStatus derives the Ready condition and patches the status of
+// identity when it changed.
+func (r *MTLSIdentityReconciler) updateIdentityStatus(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	status *automtlsv1alpha1.MTLSIdentityStatus) error {
+	setReadyCondition(&status.Conditions)
+	if equality.Semantic.DeepEqual(*status, identity.Status) {
+		return nil
+	}
+	patched := identity.DeepCopy()
+	patched.Status = *status
+	return r.Status().Patch(ctx, patched, client.MergeFrom(identity))
+}
+
+// teardownIdentity removes the mounts of a deleted identity from the workloads,
+// deletes its Certificate, Secret and the CA copy once nothing else in the namespace
+// uses it, and releases the identity.
+func (r *MTLSIdentityReconciler) teardownIdentity(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	log logr.Logger) error {
+	if !controllerutil.ContainsFinalizer(identity, identityFinalizer) {
+		return nil
+	}
+
+	services, identities, err := mtlsUsers(ctx, r.Client, identity.Namespace)
+	if err != nil {
+		return err
+	}
+	// Other identities still managed in the namespace
+	others := slices.DeleteFunc(identities, func(other automtlsv1alpha1.MTLSIdentity) bool {
+		return other.Name == identity.Name
+	})
+
+	if err := r.unmountIdentity(ctx, identity, nil, services, others, log); err != nil {
+		return err
+	}
+
+	// The Certificate is garbage collected with the identity, the Secret is not
+	cert := &certmanagerv1.Certificate{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: identity.Namespace, Name: identityCertName(identity.Name)}, cert)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && metav1.IsControlledBy(cert, identity) {
+		if err := r.Delete(ctx, cert); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: identity.Namespace, Name: identitySecretName(identity.Name)}, secret)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && secret.Annotations[generatedForAnnotation] == identityGeneratedFor(identity) {
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	if len(services) == 0 && len(others) == 0 {
+		if err := deleteCACopy(ctx, r.Client, identity.Namespace, log); err != nil {
+			return err
+		}
+	}
+
+	patched := identity.DeepCopy()
+	controllerutil.RemoveFinalizer(patched, identityFinalizer)
+	err = r.Patch(ctx, patched, client.MergeFromWithOptions(identity, client.MergeFromWithOptimisticLock{}))
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	log.Info("Tore down mTLS for identity", "identity", identity.Name)
+	r.Recorder.Event(identity, corev1.EventTypeNormal, "CleanupDone",
+		"Removed the certificate mounts, Certificate and Secret created for this identity")
+	return nil
+}
+
+// unmountIdentity removes the mounts and secret hash of identity from the workloads
+// carrying them, except from keep. The CA volume goes too unless one of services or the other
+// identities still uses it.
+func (r *MTLSIdentityReconciler) unmountIdentity(ctx context.Context, identity *automtlsv1alpha1.MTLSIdentity,
+	keep []workload, services []corev1.Service, others []automtlsv1alpha1.MTLSIdentity, log logr.Logger) error {
+	workloads, err := listWorkloads(ctx, r.Client, identity.Namespace)
+	if err != nil {
+		return err
+	}
+	certVolume := identitySecretName(identity.Name)
+	hashKey := identityHashAnnotation(identity.Name)
+	for _, w := range workloads {
+		template := podTemplate(w.Object)
+		_, stamped := template.Annotations[hashKey]
+		if (!stamped && !hasSecretVolume(&template.Spec, certVolume)) || slices.ContainsFunc(keep, func(k workload) bool {
+			return k.String() == w.String()
+		}) {
+			continue
+		}
+		volumes := []string{certVolume}
+		if !caStillUsed(template, services, others) {
+			volumes = append(volumes, caCertSecretName)
+		}
+
+		patched, err := patchPodTemplate(ctx, r.Client, w, func(template *corev1.PodTemplateSpec) (bool, error) {
+			removed := setTemplateAnnotations(template, map[string]string{hashKey: ""})
+			return removeMounts(&template.Spec, volumes...) || removed, nil
+		})
+		if errors.Is(err, errImmutableTemplate) {
+			log.Info("Cannot remove certificate mounts from workload with immutable pod template", "workload", w.String(), "identity", identity.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.String(), err)
+		}
+		if patched {
+			workloadPatches.WithLabelValues(identity.Namespace).Inc()
+			r.Recorder.Eventf(w.Object, corev1.EventTypeNormal, "MountsRemoved",
+				"Removed the certificate mounts of MTLSIdentity %s", identity.Name)
+		}
+		log.Info("Removed certificate mounts from workload", "workload", w.String(), "identity", identity.Name)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MTLSIdentityReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Only spec changes of workloads matter, status updates of busy workloads would
+	// flood the queue
+	workloadHandler := handler.EnqueueRequestsFromMapFunc(r.identitiesForWorkload)
+	workloadPredicates := builder.WithPredicates(predicate.GenerationChangedPredicate{})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&automtlsv1alpha1.MTLSIdentity{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&certmanagerv1.Certificate{}).
+		Watches(&automtlsv1alpha1.AutoMTLSConfig{}, handler.EnqueueRequestsFromMapFunc(r.identitiesForConfig),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.identitiesForSecret)).
+		Watches(&appsv1.Deployment{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.StatefulSet{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.DaemonSet{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.ReplicaSet{}, workloadHandler, workloadPredicates).
+		Watches(&batchv1.Job{}, workloadHandler, workloadPredicates).
+		Watches(&batchv1.CronJob{}, workloadHandler, workloadPredicates).
+		Complete(r)
+}
+
+// identitiesForConfig maps the AutoMTLSConfig to every MTLSIdentity, so issuer,
+// lifetime, key and mount changes reach their Certificates and workloads.
+func (r *MTLSIdentityReconciler) identitiesForConfig(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != automtlsv1alpha1.AutoMTLSConfigName {
+		return nil
+	}
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := r.List(ctx, &identityList); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(identityList.Items))
+	for i := range identityList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&identityList.Items[i])})
+	}
+	return requests
+}
+
+// identitiesForWorkload maps a workload to the MTLSIdentities selecting it, or whose
+// certificate or secret hash it still carries.
+func (r *MTLSIdentityReconciler) identitiesForWorkload(ctx context.Context, obj client.Object) []reconcile.Request {
+	kind := workloadKind(obj)
+	if kind == "" || ownedBy(obj, "Deployment") || ownedBy(obj, "CronJob") {
+		return nil
+	}
+
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := r.List(ctx, &identityList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range identityList.Items {
+		identity := &identityList.Items[i]
+		template := podTemplate(obj)
+		_, stamped := template.Annotations[identityHashAnnotation(identity.Name)]
+		if identitySelects(identity, workload{kind, obj}) || stamped ||
+			hasSecretVolume(&template.Spec, identitySecretName(identity.Name)) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(identity)})
+		}
+	}
+	return requests
+}
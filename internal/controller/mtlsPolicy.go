@@ -0,0 +1,423 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// Annotations read from managed Services.
+const (
+	enabledAnnotation     = "auto-mtls.kupher.io/enabled"
+	durationAnnotation    = "auto-mtls.kupher.io/duration"
+	renewBeforeAnnotation = "auto-mtls.kupher.io/renew-before"
+
+	keyAlgorithmAnnotation      = "auto-mtls.kupher.io/key-algorithm"
+	keySizeAnnotation           = "auto-mtls.kupher.io/key-size"
+	keyRotationPolicyAnnotation = "auto-mtls.kupher.io/key-rotation-policy"
+
+	certMountPathAnnotation = "auto-mtls.kupher.io/cert-mount-path"
+	caMountPathAnnotation   = "auto-mtls.kupher.io/ca-mount-path"
+	containersAnnotation    = "auto-mtls.kupher.io/containers"
+
+	modeAnnotation = "auto-mtls.kupher.io/mode"
+
+	allowedCallersAnnotation = "auto-mtls.kupher.io/allowed-callers"
+
+	restartOnRotationAnnotation = "auto-mtls.kupher.io/restart-on-rotation"
+)
+
+// Certificate modes set with modeAnnotation.
+const (
+	// modeServer issues a certificate for serving TLS on the Service.
+	modeServer = "server"
+	// modeClient issues a certificate the workload presents to servers. The Service
+	// only selects the workload and needs no ports.
+	modeClient = "client"
+	// modeBoth issues a certificate usable for both, the default.
+	modeBoth = "both"
+)
+
+// minCertificateDuration is the shortest certificate lifetime cert-manager accepts.
+const minCertificateDuration = time.Hour
+
+// mtlsSettings holds the effective mTLS configuration for a single Service.
+type mtlsSettings struct {
+	issuerRef certmanagermetav1.ObjectReference
+	duration  time.Duration
+	// renewBefore is zero until set explicitly, then defaultRenewBefore applies.
+	renewBefore time.Duration
+	// privateKey is nil when cert-manager's defaults apply.
+	privateKey    *certmanagerv1.CertificatePrivateKey
+	certMountPath string
+	caMountPath   string
+	// containers receive the mounts; every container when empty.
+	containers []string
+	// caSource is the trust bundle Secret whose ca.crt is copied into the Service namespace.
+	caSource types.NamespacedName
+	// mountMode is MountModePatch or MountModeWebhook.
+	mountMode string
+	// trustDomain of the SPIFFE IDs in the Service certificate.
+	trustDomain string
+	// mode is modeServer, modeClient or modeBoth.
+	mode string
+	// allowedCallers are the SPIFFE IDs allowed to call the Service. No caller policy
+	// is rendered when nil.
+	allowedCallers []string
+	// policyMountPath is where the caller policy is mounted, next to the certificate
+	// at <certMountPath>-policy.
+	policyMountPath string
+	// restartOnRotation stamps a hash of the mounted Secrets on the pod templates so
+	// the workloads roll when the certificate or the CA changes.
+	restartOnRotation bool
+}
+
+// resolveMTLSSettings merges the Service annotations and the given Automtls policy
+// (if any) over the defaults derived from the cluster config. Annotations win over
+// the policy. An error is returned for invalid annotation values.
+func resolveMTLSSettings(config *automtlsv1alpha1.AutoMTLSConfigSpec, policy *automtlsv1alpha1.Automtls,
+	svc *corev1.Service) (mtlsSettings, error) {
+	settings := defaultMTLSSettings(config)
+	if policy != nil {
+		applyPolicySettings(&settings, policy)
+	}
+
+	annotations := svc.GetAnnotations()
+	if value, ok := annotations[durationAnnotation]; ok {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return settings, fmt.Errorf("invalid %s annotation %q: %w", durationAnnotation, value, err)
+		}
+		settings.duration = duration
+	}
+	if value, ok := annotations[renewBeforeAnnotation]; ok {
+		renewBefore, err := time.ParseDuration(value)
+		if err != nil {
+			return settings, fmt.Errorf("invalid %s annotation %q: %w", renewBeforeAnnotation, value, err)
+		}
+		if renewBefore <= 0 {
+			return settings, fmt.Errorf("invalid %s annotation %q: must be positive", renewBeforeAnnotation, value)
+		}
+		settings.renewBefore = renewBefore
+	}
+
+	if value, ok := annotations[modeAnnotation]; ok {
+		switch value {
+		case modeServer, modeClient, modeBoth:
+			settings.mode = value
+		default:
+			return settings, fmt.Errorf("invalid %s annotation %q: must be %s, %s or %s",
+				modeAnnotation, value, modeServer, modeClient, modeBoth)
+		}
+	}
+
+	if value, ok := annotations[restartOnRotationAnnotation]; ok {
+		restart, err := strconv.ParseBool(value)
+		if err != nil {
+			return settings, fmt.Errorf("invalid %s annotation %q: must be true or false", restartOnRotationAnnotation, value)
+		}
+		settings.restartOnRotation = restart
+	}
+
+	if value, ok := annotations[certMountPathAnnotation]; ok {
+		settings.certMountPath = value
+	}
+	if value, ok := annotations[caMountPathAnnotation]; ok {
+		settings.caMountPath = value
+	}
+	if value, ok := annotations[containersAnnotation]; ok {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				settings.containers = append(settings.containers, name)
+			}
+		}
+	}
+	if value, ok := annotations[allowedCallersAnnotation]; ok {
+		settings.allowedCallers = []string{}
+		for _, caller := range strings.Split(value, ",") {
+			if caller = strings.TrimSpace(caller); caller != "" {
+				settings.allowedCallers = append(settings.allowedCallers, caller)
+			}
+		}
+	}
+	if settings.allowedCallers != nil {
+		ids, err := callerIDs(settings.trustDomain, settings.allowedCallers)
+		if err != nil {
+			return settings, err
+		}
+		settings.allowedCallers = ids
+		// Derived from the certificate path so Services selecting the same Pod do not clash
+		settings.policyMountPath = path.Clean(settings.certMountPath) + "-policy"
+		if settings.policyMountPath == path.Clean(settings.caMountPath) {
+			return settings, fmt.Errorf("CA mount path %q is taken by the caller policy", settings.caMountPath)
+		}
+	}
+
+	key, err := privateKeyFromAnnotations(config.PrivateKey, annotations)
+	if err != nil {
+		return settings, err
+	}
+	if settings.privateKey, err = privateKeySpec(key); err != nil {
+		return settings, err
+	}
+	if err := validateMTLSSettings(settings); err != nil {
+		return settings, err
+	}
+	if settings.renewBefore == 0 {
+		settings.renewBefore = defaultRenewBefore(settings.duration)
+	}
+	return settings, nil
+}
+
+// defaultMTLSSettings returns the settings used when neither a policy nor annotations
+// override them.
+func defaultMTLSSettings(config *automtlsv1alpha1.AutoMTLSConfigSpec) mtlsSettings {
+	return mtlsSettings{
+		issuerRef:     serviceIssuerRef(config),
+		duration:      8760 * time.Hour, // 1 year
+		certMountPath: "/etc/tls",
+		caMountPath:   "/etc/ca",
+		caSource: types.NamespacedName{
+			Namespace: config.CANamespace,
+			Name:      config.TrustBundleSecretName,
+		},
+		mountMode:   config.MountMode,
+		trustDomain: config.TrustDomain,
+		mode:        modeBoth,
+	}
+}
+
+// defaultRenewBefore returns the renew-before of certificates valid for duration when
+// none is set: a third of the duration, as cert-manager does, but at most 30 days.
+func defaultRenewBefore(duration time.Duration) time.Duration {
+	return min(duration/3, 720*time.Hour)
+}
+
+// validateMTLSSettings checks the mount paths and certificate lifetimes of settings.
+// An unset renew-before is derived from the duration and always valid.
+func validateMTLSSettings(settings mtlsSettings) error {
+	for _, mountPath := range []string{settings.certMountPath, settings.caMountPath} {
+		if !path.IsAbs(mountPath) {
+			return fmt.Errorf("mount path %q must be absolute", mountPath)
+		}
+	}
+	if path.Clean(settings.certMountPath) == path.Clean(settings.caMountPath) {
+		return fmt.Errorf("certificate and CA mount paths must differ, both are %q", settings.certMountPath)
+	}
+
+	if settings.duration < minCertificateDuration {
+		return fmt.Errorf("certificate duration %s is shorter than the minimum of %s",
+			settings.duration, minCertificateDuration)
+	}
+	if settings.renewBefore != 0 && (settings.renewBefore < 0 || settings.renewBefore >= settings.duration) {
+		return fmt.Errorf("renew-before %s must be positive and shorter than the duration %s",
+			settings.renewBefore, settings.duration)
+	}
+	return nil
+}
+
+// certificateUsages returns the key usages of a certificate issued in mode.
+func certificateUsages(mode string) []certmanagerv1.KeyUsage {
+	usages := []certmanagerv1.KeyUsage{certmanagerv1.UsageDigitalSignature, certmanagerv1.UsageKeyEncipherment}
+	if mode != modeClient {
+		usages = append(usages, certmanagerv1.UsageServerAuth)
+	}
+	if mode != modeServer {
+		usages = append(usages, certmanagerv1.UsageClientAuth)
+	}
+	return usages
+}
+
+// applyPolicySettings overrides settings with the fields set on the Automtls policy.
+func applyPolicySettings(settings *mtlsSettings, policy *automtlsv1alpha1.Automtls) {
+	spec := policy.Spec
+	if spec.IssuerRef != nil {
+		settings.issuerRef = certmanagermetav1.ObjectReference{
+			Name:  spec.IssuerRef.Name,
+			Kind:  spec.IssuerRef.Kind,
+			Group: spec.IssuerRef.Group,
+		}
+	}
+	if spec.Duration != nil {
+		settings.duration = spec.Duration.Duration
+	}
+	if spec.RenewBefore != nil {
+		settings.renewBefore = spec.RenewBefore.Duration
+	}
+	if spec.CertMountPath != "" {
+		settings.certMountPath = spec.CertMountPath
+	}
+	if spec.CAMountPath != "" {
+		settings.caMountPath = spec.CAMountPath
+	}
+	if spec.AllowedCallers != nil {
+		settings.allowedCallers = spec.AllowedCallers
+	}
+}
+
+// mtlsEnabled reports whether svc should get mTLS. The enabled annotation opts a
+// Service in on its own, and setting it to "false" opts out of a selecting policy.
+func mtlsEnabled(svc *corev1.Service, policy *automtlsv1alpha1.Automtls) bool {
+	switch svc.GetAnnotations()[enabledAnnotation] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return policy != nil
+}
+
+// policySelects reports whether the Automtls policy selects the given Service.
+func policySelects(policy *automtlsv1alpha1.Automtls, svc client.Object) bool {
+	if policy.Namespace != svc.GetNamespace() {
+		return false
+	}
+	if policy.Spec.ServiceSelector == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(policy.Spec.ServiceSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(svc.GetLabels()))
+}
+
+// policyForService returns the Automtls policy selecting svc, or nil if there is none.
+// When several policies select the same Service the oldest one wins.
+func policyForService(ctx context.Context, c client.Reader, svc client.Object) (*automtlsv1alpha1.Automtls, error) {
+	var policyList automtlsv1alpha1.AutomtlsList
+	if err := c.List(ctx, &policyList, client.InNamespace(svc.GetNamespace())); err != nil {
+		return nil, err
+	}
+
+	var matched []automtlsv1alpha1.Automtls
+	for _, policy := range policyList.Items {
+		if policySelects(&policy, svc) {
+			matched = append(matched, policy)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		ti, tj := matched[i].CreationTimestamp, matched[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return matched[i].Name < matched[j].Name
+	})
+	return &matched[0], nil
+}
+
+// isMTLSService is the event filter for Services: it passes Services that opted in
+// with the enabled annotation, carry the cleanup finalizer, or sit in a namespace with
+// an Automtls policy, unless they opted out. Which policy selects the Service is
+// decided by the reconciler. The policies are read from the informer cache.
+func (r *AutomtlsReconciler) isMTLSService(obj client.Object) bool {
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return false
+	}
+	if controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+		return true
+	}
+	switch svc.GetAnnotations()[enabledAnnotation] {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	var policies automtlsv1alpha1.AutomtlsList
+	if err := r.List(context.Background(), &policies, client.InNamespace(svc.Namespace)); err != nil {
+		// Let the reconciler look again
+		return true
+	}
+	return len(policies.Items) > 0
+}
+
+// managesService reports whether the reconciler has work for svc: mTLS is enabled by
+// its annotation or an Automtls policy, or it still carries the cleanup finalizer.
+func (r *AutomtlsReconciler) managesService(ctx context.Context, svc *corev1.Service) bool {
+	if controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+		return true
+	}
+	policy, err := policyForService(ctx, r.Client, svc)
+	if err != nil {
+		return false
+	}
+	return mtlsEnabled(svc, policy)
+}
+
+// servicesForConfig maps the AutoMTLSConfig to every managed Service, those carrying
+// the cleanup finalizer or the managed-by label, so issuer, lifetime, key and mount
+// changes reach their Certificates and workloads.
+func (r *AutomtlsReconciler) servicesForConfig(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != automtlsv1alpha1.AutoMTLSConfigName {
+		return nil
+	}
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, svc := range svcList.Items {
+		if controllerutil.ContainsFinalizer(&svc, serviceFinalizer) || svc.Labels[managedByLabel] == managedByValue {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&svc)})
+		}
+	}
+	return requests
+}
+
+// servicesForPolicy maps an Automtls policy to the Services it selects.
+func (r *AutomtlsReconciler) servicesForPolicy(ctx context.Context, obj client.Object) []reconcile.Request {
+	policy, ok := obj.(*automtlsv1alpha1.Automtls)
+	if !ok {
+		return nil
+	}
+
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList, client.InNamespace(policy.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, svc := range svcList.Items {
+		if policySelects(policy, &svc) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&svc)})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+func TestResolveMTLSSettingsLifetime(t *testing.T) {
+	config := &automtlsv1alpha1.AutoMTLSConfigSpec{}
+	defaultClusterConfig(config)
+	renewBeforePolicy := &automtlsv1alpha1.Automtls{
+		Spec: automtlsv1alpha1.AutomtlsSpec{RenewBefore: &metav1.Duration{Duration: 360 * time.Hour}},
+	}
+
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		policy          *automtlsv1alpha1.Automtls
+		wantDuration    time.Duration
+		wantRenewBefore time.Duration
+		wantErr         bool
+	}{
+		{name: "defaults", wantDuration: 8760 * time.Hour, wantRenewBefore: 720 * time.Hour},
+		{name: "duration only", annotations: map[string]string{durationAnnotation: "24h"},
+			wantDuration: 24 * time.Hour, wantRenewBefore: 8 * time.Hour},
+		{name: "duration and renew-before", annotations: map[string]string{durationAnnotation: "24h", renewBeforeAnnotation: "2h"},
+			wantDuration: 24 * time.Hour, wantRenewBefore: 2 * time.Hour},
+		{name: "renew-before from policy", policy: renewBeforePolicy,
+			wantDuration: 8760 * time.Hour, wantRenewBefore: 360 * time.Hour},
+		{name: "explicit renew-before too long", annotations: map[string]string{durationAnnotation: "24h", renewBeforeAnnotation: "24h"},
+			wantErr: true},
+		{name: "policy renew-before too long", annotations: map[string]string{durationAnnotation: "24h"}, policy: renewBeforePolicy,
+			wantErr: true},
+		{name: "zero renew-before", annotations: map[string]string{renewBeforeAnnotation: "0s"}, wantErr: true},
+		{name: "duration too short", annotations: map[string]string{durationAnnotation: "30m"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "ns", Annotations: tt.annotations}}
+			settings, err := resolveMTLSSettings(config, tt.policy, svc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveMTLSSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if settings.duration != tt.wantDuration || settings.renewBefore != tt.wantRenewBefore {
+				t.Errorf("duration, renewBefore = %s, %s, want %s, %s",
+					settings.duration, settings.renewBefore, tt.wantDuration, tt.wantRenewBefore)
+			}
+		})
+	}
+}
+
+func TestServicesForConfig(t *testing.T) {
+	managed := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name: "web", Namespace: "shop", Finalizers: []string{serviceFinalizer},
+	}}
+	labelled := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+		Name: "api", Namespace: "shop", Labels: map[string]string{managedByLabel: managedByValue},
+	}}
+	unmanaged := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop"}}
+	r := &AutomtlsReconciler{
+		Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(managed, labelled, unmanaged).Build(),
+	}
+
+	// Editing the config, for example switching the issuer, re-reconciles the managed Services
+	config := &automtlsv1alpha1.AutoMTLSConfig{ObjectMeta: metav1.ObjectMeta{Name: automtlsv1alpha1.AutoMTLSConfigName}}
+	got := r.servicesForConfig(context.Background(), config)
+	want := []reconcile.Request{
+		{NamespacedName: client.ObjectKeyFromObject(labelled)},
+		{NamespacedName: client.ObjectKeyFromObject(managed)},
+	}
+	slices.SortFunc(got, func(a, b reconcile.Request) int { return strings.Compare(a.Name, b.Name) })
+	if !slices.Equal(got, want) {
+		t.Errorf("servicesForConfig() = %v, want %v", got, want)
+	}
+
+	other := &automtlsv1alpha1.AutoMTLSConfig{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	if got := r.servicesForConfig(context.Background(), other); len(got) > 0 {
+		t.Errorf("servicesForConfig() = %v for a config that is not read", got)
+	}
+}
+
+func TestIsMTLSService(t *testing.T) {
+	policy := &automtlsv1alpha1.Automtls{ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "shop"}}
+	r := &AutomtlsReconciler{
+		Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(policy).Build(),
+	}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		finalizers  []string
+		want        bool
+	}{
+		{name: "opted in", namespace: "blog", annotations: map[string]string{enabledAnnotation: "true"}, want: true},
+		{name: "cleanup pending", namespace: "blog", finalizers: []string{serviceFinalizer}, want: true},
+		{name: "namespace with a policy", namespace: "shop", want: true},
+		{name: "opted out of the policy", namespace: "shop", annotations: map[string]string{enabledAnnotation: "false"}},
+		{
+			name: "opted out with cleanup pending", namespace: "shop",
+			annotations: map[string]string{enabledAnnotation: "false"}, finalizers: []string{serviceFinalizer}, want: true,
+		},
+		{name: "namespace without a policy", namespace: "blog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{
+				Name: "web", Namespace: tt.namespace, Annotations: tt.annotations, Finalizers: tt.finalizers,
+			}}
+			if got := r.isMTLSService(svc); got != tt.want {
+				t.Errorf("isMTLSService() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
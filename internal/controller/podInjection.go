@@ -0,0 +1,138 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// InjectPodMounts adds the certificate and CA mounts of every mTLS-enabled Service and
+// every MTLSIdentity in namespace that selects pod. It does nothing unless the
+// AutoMTLSConfig mount mode is Webhook. The names of the Services, and the
+// MTLSIdentities as "MTLSIdentity/<name>", whose mounts were injected are returned.
+//
+// Services and identities with invalid settings, or whose mounts conflict with those
+// of another one selecting the same Pod, are skipped so the Pod can still start.
+func InjectPodMounts(ctx context.Context, c client.Reader, namespace string, pod *corev1.Pod) ([]string, error) {
+	log := logf.FromContext(ctx)
+
+	config, err := loadClusterConfig(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if config.MountMode != automtlsv1alpha1.MountModeWebhook {
+		return nil, nil
+	}
+
+	var svcList corev1.ServiceList
+	if err := c.List(ctx, &svcList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	var injected []string
+	// Certificate volumes of the injected Services and identities, which share the CA volume
+	var certVolumes []string
+	for i := range svcList.Items {
+		svc := &svcList.Items[i]
+		if len(svc.Spec.Selector) == 0 ||
+			!labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		policy, err := policyForService(ctx, c, svc)
+		if err != nil {
+			return injected, err
+		}
+		if !mtlsEnabled(svc, policy) {
+			continue
+		}
+		settings, err := resolveMTLSSettings(config, policy, svc)
+		if err != nil {
+			log.Info("Skipping service with invalid mTLS settings", "service", svc.Name, "reason", err.Error())
+			continue
+		}
+
+		mounts := serviceMounts(svc.Name, settings)
+		if _, err := applyMounts(&pod.Spec, mounts, settings.containers, certVolumes); err != nil {
+			log.Info("Skipping service whose mounts do not fit the pod", "service", svc.Name, "reason", err.Error())
+			continue
+		}
+		certVolumes = append(certVolumes, mounts[0].volumeName)
+		injected = append(injected, svc.Name)
+	}
+
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := c.List(ctx, &identityList, client.InNamespace(namespace)); err != nil {
+		return injected, err
+	}
+	for i := range identityList.Items {
+		identity := &identityList.Items[i]
+		if !identity.DeletionTimestamp.IsZero() {
+			continue
+		}
+		selected, err := identitySelectsPod(ctx, c, identity, pod)
+		if err != nil {
+			return injected, err
+		}
+		if !selected {
+			continue
+		}
+		name := "MTLSIdentity/" + identity.Name
+		settings, err := identitySettings(config, identity)
+		if err != nil {
+			log.Info("Skipping identity with invalid mTLS settings", "identity", identity.Name, "reason", err.Error())
+			continue
+		}
+
+		mounts := identityMounts(identity, settings)
+		if _, err := applyMounts(&pod.Spec, mounts, settings.containers, certVolumes); err != nil {
+			log.Info("Skipping identity whose mounts do not fit the pod", "identity", identity.Name, "reason", err.Error())
+			continue
+		}
+		certVolumes = append(certVolumes, mounts[0].volumeName)
+		injected = append(injected, name)
+	}
+	return injected, nil
+}
+
+// identitySelectsPod reports whether identity selects pod: its selector matches the
+// Pod labels, or the Pod carries the pod template labels of the referenced workload.
+func identitySelectsPod(ctx context.Context, c client.Reader, identity *automtlsv1alpha1.MTLSIdentity,
+	pod *corev1.Pod) (bool, error) {
+	ref := identity.Spec.WorkloadRef
+	if ref == nil {
+		selector := identityLabelSelector(identity)
+		return selector != nil && selector.Matches(labels.Set(pod.Labels)), nil
+	}
+
+	obj := newWorkloadObject(ref.Kind)
+	if obj == nil {
+		return false, nil
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: identity.Namespace, Name: ref.Name}, obj); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	template := podTemplate(obj)
+	return len(template.Labels) > 0 && selectorMatches(pod.Labels, template.Labels), nil
+}
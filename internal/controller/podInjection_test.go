@@ -0,0 +1,160 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// newTestScheme returns a scheme with the built-in and the auto-mtls types.
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := automtlsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestInjectPodMountsSkipsOutsideWebhookMode(t *testing.T) {
+	scheme := newTestScheme(t)
+
+	for _, config := range []*automtlsv1alpha1.AutoMTLSConfig{
+		nil, // The default mount mode patches the workloads
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: automtlsv1alpha1.AutoMTLSConfigName},
+			Spec:       automtlsv1alpha1.AutoMTLSConfigSpec{MountMode: automtlsv1alpha1.MountModePatch},
+		},
+	} {
+		builder := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			List: func(context.Context, client.WithWatch, client.ObjectList, ...client.ListOption) error {
+				t.Error("InjectPodMounts listed objects outside the Webhook mount mode")
+				return nil
+			},
+		})
+		if config != nil {
+			builder = builder.WithObjects(config)
+		}
+
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+		injected, err := InjectPodMounts(context.Background(), builder.Build(), "shop", pod)
+		if err != nil || injected != nil {
+			t.Errorf("InjectPodMounts() = %v, %v, want nothing injected", injected, err)
+		}
+	}
+}
+
+func TestInjectPodMountsWebhookMode(t *testing.T) {
+	config := &automtlsv1alpha1.AutoMTLSConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: automtlsv1alpha1.AutoMTLSConfigName},
+		Spec:       automtlsv1alpha1.AutoMTLSConfigSpec{MountMode: automtlsv1alpha1.MountModeWebhook},
+	}
+	service := func(name string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop",
+				Annotations: map[string]string{enabledAnnotation: "true"}},
+			Spec: corev1.ServiceSpec{Selector: map[string]string{"app": name}},
+		}
+	}
+	identity := &automtlsv1alpha1.MTLSIdentity{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "shop"},
+		Spec: automtlsv1alpha1.MTLSIdentitySpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "worker"}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).
+		WithObjects(config, service("web"), service("api"), identity).Build()
+
+	pod := func(app string, volumes ...corev1.Volume) *corev1.Pod {
+		container := corev1.Container{Name: "app"}
+		for _, v := range volumes {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{Name: v.Name, MountPath: "/etc/tls"})
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: app, Namespace: "shop", Labels: map[string]string{"app": app}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container}, Volumes: volumes},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		pod          *corev1.Pod
+		wantInjected []string
+		wantVolumes  []string
+	}{
+		{
+			name:         "service mounts",
+			pod:          pod("web"),
+			wantInjected: []string{"web"},
+			wantVolumes:  []string{"web-cert-tls", caCertSecretName},
+		},
+		{
+			name:         "identity mounts",
+			pod:          pod("worker"),
+			wantInjected: []string{"MTLSIdentity/worker"},
+			wantVolumes:  []string{identitySecretName("worker"), caCertSecretName},
+		},
+		{
+			// The certificate mount path is taken, the Pod starts without the mounts
+			name:        "mount conflict",
+			pod:         pod("api", corev1.Volume{Name: "data"}),
+			wantVolumes: []string{"data"},
+		},
+		{
+			name: "not selected",
+			pod:  pod("db"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := tt.pod.DeepCopy()
+			injected, err := InjectPodMounts(context.Background(), c, "shop", tt.pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(injected, tt.wantInjected) {
+				t.Errorf("InjectPodMounts() = %v, want %v", injected, tt.wantInjected)
+			}
+			var volumes []string
+			for _, v := range tt.pod.Spec.Volumes {
+				volumes = append(volumes, v.Name)
+			}
+			if !slices.Equal(volumes, tt.wantVolumes) {
+				t.Errorf("pod volumes = %v, want %v", volumes, tt.wantVolumes)
+			}
+			if len(tt.wantInjected) == 0 && !equality.Semantic.DeepEqual(tt.pod, original) {
+				t.Errorf("pod changed although nothing was injected: %v", tt.pod.Spec)
+			}
+		})
+	}
+}
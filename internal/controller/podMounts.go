@@ -0,0 +1,229 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// caCertSecretName is the namespace copy of the cluster CA certificate.
+const caCertSecretName = "auto-mtls-ca-cert"
+
+// managedByLabel marks shared objects the operator created, such as the CA copies.
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "auto-mtls"
+)
+
+// errMountConflict is returned when the mounts of a Service or MTLSIdentity clash
+// with the mounts another one already put into the pod.
+var errMountConflict = errors.New("mount conflict")
+
+// secretMount is a Secret volume the operator mounts into workloads.
+type secretMount struct {
+	volumeName string
+	secretName string
+	mountPath  string
+	// configMap makes the volume the ConfigMap secretName instead of a Secret.
+	configMap bool
+	// required keeps the Pods from starting while the object is missing, instead of
+	// mounting an empty directory.
+	required bool
+}
+
+// serviceMounts returns the certificate and CA mounts for the Service svcName, and
+// the caller policy mount when allowed callers are set.
+func serviceMounts(svcName string, settings mtlsSettings) []secretMount {
+	mounts := []secretMount{
+		{
+			volumeName: svcName + "-cert-tls",
+			secretName: svcName + "-cert-tls", // Secret name spacific to service
+			mountPath:  settings.certMountPath,
+		},
+		{
+			volumeName: caCertSecretName,
+			secretName: caCertSecretName,
+			mountPath:  settings.caMountPath,
+		},
+	}
+	if settings.allowedCallers != nil {
+		mounts = append(mounts, secretMount{
+			volumeName: callerPolicyName(svcName),
+			secretName: callerPolicyName(svcName),
+			mountPath:  settings.policyMountPath,
+			configMap:  true,
+			// A missing policy must not read as "no policy"
+			required: true,
+		})
+	}
+	return mounts
+}
+
+// applyMounts makes podSpec carry the volumes of mounts and mounts them in the
+// containers named in containers (every container when empty) and in no other
+// container. Mount paths that drifted from the settings are corrected. It reports
+// whether podSpec was changed.
+//
+// others are the certificate volumes of the other Services and identities that may
+// share the CA volume. An errMountConflict is returned, and podSpec left alone, when
+// a mount path is taken by another volume, or when the CA volume is shared with one
+// of others and mounted at another path or into other containers.
+func applyMounts(podSpec *corev1.PodSpec, mounts []secretMount, containers []string, others []string) (bool, error) {
+	if len(containers) > 0 && !slices.ContainsFunc(podSpec.Containers, func(c corev1.Container) bool {
+		return slices.Contains(containers, c.Name)
+	}) {
+		return false, fmt.Errorf("none of the containers %v found in the pod template", containers)
+	}
+	if err := mountConflict(podSpec, mounts, containers, others); err != nil {
+		return false, err
+	}
+
+	changed := false
+	for _, m := range mounts {
+		// Add the volume if missing, or correct whether it is optional
+		idx := slices.IndexFunc(podSpec.Volumes, func(v corev1.Volume) bool { return v.Name == m.volumeName })
+		if idx < 0 {
+			source := corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: m.secretName,
+					Optional:   ptrBool(!m.required),
+				},
+			}
+			if m.configMap {
+				source = corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: m.secretName},
+						Optional:             ptrBool(!m.required),
+					},
+				}
+			}
+			podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{Name: m.volumeName, VolumeSource: source})
+			changed = true
+		} else if setVolumeOptional(&podSpec.Volumes[idx], !m.required) {
+			changed = true
+		}
+
+		for i := range podSpec.Containers {
+			container := &podSpec.Containers[i]
+			wanted := len(containers) == 0 || slices.Contains(containers, container.Name)
+			idx := slices.IndexFunc(container.VolumeMounts, func(vm corev1.VolumeMount) bool {
+				return vm.Name == m.volumeName
+			})
+
+			switch {
+			case wanted && idx < 0:
+				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+					Name:      m.volumeName,
+					MountPath: m.mountPath,
+					ReadOnly:  true,
+				})
+				changed = true
+			case wanted && container.VolumeMounts[idx].MountPath != m.mountPath:
+				container.VolumeMounts[idx].MountPath = m.mountPath
+				changed = true
+			case !wanted && idx >= 0:
+				// Containers that are not targeted must not see the keys
+				container.VolumeMounts = slices.Delete(container.VolumeMounts, idx, idx+1)
+				changed = true
+			}
+		}
+	}
+	return changed, nil
+}
+
+// mountConflict returns an errMountConflict when mounts cannot be applied to podSpec
+// without taking over the mounts of another volume, see applyMounts.
+func mountConflict(podSpec *corev1.PodSpec, mounts []secretMount, containers []string, others []string) error {
+	caShared := slices.ContainsFunc(others, func(name string) bool { return hasSecretVolume(podSpec, name) })
+	for _, m := range mounts {
+		for _, container := range podSpec.Containers {
+			wanted := len(containers) == 0 || slices.Contains(containers, container.Name)
+			for _, vm := range container.VolumeMounts {
+				switch {
+				case wanted && vm.Name != m.volumeName && path.Clean(vm.MountPath) == path.Clean(m.mountPath):
+					return fmt.Errorf("%w: %s is already mounted at %s in container %s",
+						errMountConflict, vm.Name, vm.MountPath, container.Name)
+				case caShared && vm.Name == caCertSecretName && m.volumeName == caCertSecretName &&
+					path.Clean(vm.MountPath) != path.Clean(m.mountPath):
+					return fmt.Errorf("%w: the shared %s volume is mounted at %s in container %s, not at %s",
+						errMountConflict, caCertSecretName, vm.MountPath, container.Name, m.mountPath)
+				}
+			}
+			if caShared && m.volumeName == caCertSecretName {
+				mounted := slices.ContainsFunc(container.VolumeMounts, func(vm corev1.VolumeMount) bool {
+					return vm.Name == caCertSecretName
+				})
+				if mounted != wanted {
+					return fmt.Errorf("%w: the shared %s volume is mounted into other containers than %v",
+						errMountConflict, caCertSecretName, containers)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// removeMounts drops the named volumes from podSpec together with their mounts in
+// every container. It reports whether podSpec was changed.
+func removeMounts(podSpec *corev1.PodSpec, volumeNames ...string) bool {
+	changed := false
+	podSpec.Volumes = slices.DeleteFunc(podSpec.Volumes, func(v corev1.Volume) bool {
+		drop := slices.Contains(volumeNames, v.Name)
+		changed = changed || drop
+		return drop
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		container.VolumeMounts = slices.DeleteFunc(container.VolumeMounts, func(vm corev1.VolumeMount) bool {
+			drop := slices.Contains(volumeNames, vm.Name)
+			changed = changed || drop
+			return drop
+		})
+	}
+	return changed
+}
+
+// setVolumeOptional makes the Secret or ConfigMap volume v optional or required. It
+// reports whether v was changed.
+func setVolumeOptional(v *corev1.Volume, optional bool) bool {
+	var current **bool
+	switch {
+	case v.Secret != nil:
+		current = &v.Secret.Optional
+	case v.ConfigMap != nil:
+		current = &v.ConfigMap.Optional
+	default:
+		return false
+	}
+	if (*current != nil && **current) == optional {
+		return false
+	}
+	*current = ptrBool(optional)
+	return true
+}
+
+// hasSecretVolume reports whether podSpec has a volume named name for the Secret of the same name.
+func hasSecretVolume(podSpec *corev1.PodSpec, name string) bool {
+	return slices.ContainsFunc(podSpec.Volumes, func(v corev1.Volume) bool {
+		return v.Name == name && v.Secret != nil && v.Secret.SecretName == name
+	})
+}
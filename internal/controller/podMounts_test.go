@@ -0,0 +1,98 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+func TestApplyMountsConflicts(t *testing.T) {
+	settings := mtlsSettings{certMountPath: "/etc/tls", caMountPath: "/etc/ca"}
+	// A pod already carrying the mounts of the Service web
+	mounted := func() *corev1.PodSpec {
+		podSpec := &corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}}}
+		if _, err := applyMounts(podSpec, serviceMounts("web", settings), []string{"app"}, nil); err != nil {
+			t.Fatalf("mounting web: %v", err)
+		}
+		return podSpec
+	}
+
+	tests := []struct {
+		name         string
+		mounts       []secretMount
+		containers   []string
+		others       []string
+		wantConflict bool
+	}{
+		{
+			name:   "same service again",
+			mounts: serviceMounts("web", settings),
+			// Its own volumes do not conflict
+			containers: []string{"app"},
+		},
+		{
+			name:       "service moves its own CA",
+			mounts:     serviceMounts("web", mtlsSettings{certMountPath: "/etc/tls", caMountPath: "/etc/other-ca"}),
+			containers: []string{"app"},
+		},
+		{
+			name:         "second certificate at the same path",
+			mounts:       serviceMounts("api", settings),
+			containers:   []string{"app"},
+			others:       []string{"web-cert-tls"},
+			wantConflict: true,
+		},
+		{
+			name:       "second certificate in another container",
+			mounts:     serviceMounts("api", settings),
+			containers: []string{"sidecar"},
+			// The shared CA volume would leave the app container
+			others:       []string{"web-cert-tls"},
+			wantConflict: true,
+		},
+		{
+			name:         "shared CA at another path",
+			mounts:       serviceMounts("api", mtlsSettings{certMountPath: "/etc/api-tls", caMountPath: "/etc/other-ca"}),
+			containers:   []string{"app"},
+			others:       []string{"web-cert-tls"},
+			wantConflict: true,
+		},
+		{
+			name:       "shared CA at the same path",
+			mounts:     serviceMounts("api", mtlsSettings{certMountPath: "/etc/api-tls", caMountPath: "/etc/ca/"}),
+			containers: []string{"app"},
+			others:     []string{"web-cert-tls"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSpec := mounted()
+			before := podSpec.DeepCopy()
+			_, err := applyMounts(podSpec, tt.mounts, tt.containers, tt.others)
+			if conflict := errors.Is(err, errMountConflict); conflict != tt.wantConflict {
+				t.Fatalf("applyMounts() error = %v, want conflict %v", err, tt.wantConflict)
+			}
+			if tt.wantConflict && !equality.Semantic.DeepEqual(before, podSpec) {
+				t.Errorf("applyMounts() changed the pod spec despite the conflict")
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// validKeySizes lists the sizes accepted per key algorithm; 0 keeps cert-manager's default.
+var validKeySizes = map[certmanagerv1.PrivateKeyAlgorithm][]int{
+	certmanagerv1.RSAKeyAlgorithm:     {0, 2048, 3072, 4096},
+	certmanagerv1.ECDSAKeyAlgorithm:   {0, 256, 384, 521},
+	certmanagerv1.Ed25519KeyAlgorithm: {0},
+}
+
+// privateKeySpec converts a PrivateKey policy into a cert-manager private key spec.
+// A nil policy returns nil, which keeps cert-manager's defaults.
+func privateKeySpec(key *automtlsv1alpha1.PrivateKey) (*certmanagerv1.CertificatePrivateKey, error) {
+	if key == nil {
+		return nil, nil
+	}
+
+	spec := &certmanagerv1.CertificatePrivateKey{Size: key.Size}
+
+	if key.Algorithm != "" {
+		algorithm, ok := parseKeyAlgorithm(key.Algorithm)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key algorithm %q, must be RSA, ECDSA or Ed25519", key.Algorithm)
+		}
+		spec.Algorithm = algorithm
+	}
+
+	algorithm := spec.Algorithm
+	if algorithm == "" {
+		algorithm = certmanagerv1.RSAKeyAlgorithm
+	}
+	if !slices.Contains(validKeySizes[algorithm], key.Size) {
+		return nil, fmt.Errorf("unsupported key size %d for %s keys", key.Size, algorithm)
+	}
+
+	switch strings.ToLower(key.RotationPolicy) {
+	case "":
+	case "always":
+		spec.RotationPolicy = certmanagerv1.RotationPolicyAlways
+	case "never":
+		spec.RotationPolicy = certmanagerv1.RotationPolicyNever
+	default:
+		return nil, fmt.Errorf("unsupported key rotation policy %q, must be Always or Never", key.RotationPolicy)
+	}
+	return spec, nil
+}
+
+// privateKeyFromAnnotations overlays the key annotations of a Service on base. When
+// the algorithm annotation is set, the inherited size is dropped because it may not
+// apply to the new algorithm.
+func privateKeyFromAnnotations(base *automtlsv1alpha1.PrivateKey, annotations map[string]string) (*automtlsv1alpha1.PrivateKey, error) {
+	algorithm, hasAlgorithm := annotations[keyAlgorithmAnnotation]
+	size, hasSize := annotations[keySizeAnnotation]
+	rotationPolicy, hasRotationPolicy := annotations[keyRotationPolicyAnnotation]
+	if !hasAlgorithm && !hasSize && !hasRotationPolicy {
+		return base, nil
+	}
+
+	key := &automtlsv1alpha1.PrivateKey{}
+	if base != nil {
+		key = base.DeepCopy()
+	}
+	if hasAlgorithm {
+		key.Algorithm = algorithm
+		key.Size = 0
+	}
+	if hasSize {
+		bits, err := strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %q: %w", keySizeAnnotation, size, err)
+		}
+		key.Size = bits
+	}
+	if hasRotationPolicy {
+		key.RotationPolicy = rotationPolicy
+	}
+	return key, nil
+}
+
+// parseKeyAlgorithm maps a case-insensitive algorithm name to its cert-manager value.
+func parseKeyAlgorithm(name string) (certmanagerv1.PrivateKeyAlgorithm, bool) {
+	for algorithm := range validKeySizes {
+		if strings.EqualFold(name, string(algorithm)) {
+			return algorithm, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// The readiness checks below are registered separately, so /readyz names the one
+// that failed. The error itself is logged by the health probe handler.
+
+// CertManagerAPICheck fails while the cert-manager API group is not served.
+func CertManagerAPICheck(mapper meta.RESTMapper) healthz.Checker {
+	return func(_ *http.Request) error {
+		gk := schema.GroupKind{Group: certmanagerv1.SchemeGroupVersion.Group, Kind: "Certificate"}
+		if _, err := mapper.RESTMapping(gk, certmanagerv1.SchemeGroupVersion.Version); err != nil {
+			return fmt.Errorf("cert-manager API %s is not available: %w", certmanagerv1.SchemeGroupVersion, err)
+		}
+		return nil
+	}
+}
+
+// CAIssuerReadyCheck fails while the ClusterIssuer signing the Service certificates
+// is missing or not Ready. Namespaced external Issuers are not checked.
+func CAIssuerReadyCheck(c client.Reader) healthz.Checker {
+	return func(req *http.Request) error {
+		config, err := loadClusterConfig(req.Context(), c)
+		if err != nil {
+			return fmt.Errorf("failed to load AutoMTLSConfig: %w", err)
+		}
+		ref := serviceIssuerRef(config)
+		if ref.Kind != "ClusterIssuer" {
+			return nil
+		}
+
+		issuer := &certmanagerv1.ClusterIssuer{}
+		if err := c.Get(req.Context(), client.ObjectKey{Name: ref.Name}, issuer); err != nil {
+			return fmt.Errorf("ClusterIssuer %s: %w", ref.Name, err)
+		}
+		for _, cond := range issuer.Status.Conditions {
+			if cond.Type == certmanagerv1.IssuerConditionReady {
+				if cond.Status != certmanagermetav1.ConditionTrue {
+					return fmt.Errorf("ClusterIssuer %s is not Ready: %s", ref.Name, cond.Message)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("ClusterIssuer %s has no Ready condition", ref.Name)
+	}
+}
+
+// CASecretCheck fails while the Secret holding the CA certificates to trust does not
+// contain a parseable certificate.
+func CASecretCheck(c client.Reader) healthz.Checker {
+	return func(req *http.Request) error {
+		config, err := loadClusterConfig(req.Context(), c)
+		if err != nil {
+			return fmt.Errorf("failed to load AutoMTLSConfig: %w", err)
+		}
+		source, key, err := caSourceKey(req.Context(), c, config)
+		if err != nil {
+			return err
+		}
+
+		secret := &corev1.Secret{}
+		if err := c.Get(req.Context(), source, secret); err != nil {
+			return fmt.Errorf("CA secret %s: %w", source, err)
+		}
+		certs, err := parseCertificates(caSourceData(secret, key))
+		if err != nil {
+			return fmt.Errorf("CA secret %s: %w", source, err)
+		}
+		if len(certs) == 0 {
+			return fmt.Errorf("CA secret %s holds no certificate", source)
+		}
+		return nil
+	}
+}
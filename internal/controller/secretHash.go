@@ -0,0 +1,207 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// serviceHashAnnotationPrefix and identityHashAnnotationPrefix prefix the pod template
+// annotation holding the hash of the Secrets mounted for a Service or MTLSIdentity. Each
+// one selecting the workload gets its own, and the kind is part of the key so a Service
+// and an MTLSIdentity with the same name do not share it.
+const (
+	serviceHashAnnotationPrefix  = "service.secret-hash.auto-mtls.kupher.io/"
+	identityHashAnnotationPrefix = "identity.secret-hash.auto-mtls.kupher.io/"
+)
+
+// serviceHashAnnotation is the pod template annotation of the Service name.
+func serviceHashAnnotation(name string) string {
+	return serviceHashAnnotationPrefix + name
+}
+
+// identityHashAnnotation is the pod template annotation of the MTLSIdentity name.
+func identityHashAnnotation(name string) string {
+	return identityHashAnnotationPrefix + name
+}
+
+// secretsHash returns a hash over the data of the named Secrets in namespace, or ""
+// while one of them does not exist yet.
+func secretsHash(ctx context.Context, c client.Reader, namespace string, names ...string) (string, error) {
+	h := sha256.New()
+	for _, name := range names {
+		secret := &corev1.Secret{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", nil
+			}
+			return "", err
+		}
+		for _, key := range slices.Sorted(maps.Keys(secret.Data)) {
+			fmt.Fprintf(h, "%s/%s:%d:", name, key, len(secret.Data[key]))
+			h.Write(secret.Data[key])
+		}
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// secretHashAnnotations returns the pod template annotations to stamp under key for a
+// Service or MTLSIdentity: the hash of its certificate Secret certSecret and of the CA
+// copy when restarts are enabled, or an empty value removing a previous hash when they
+// are not. It returns nil while the Secrets do not exist yet, so the workloads are not
+// rolled before there is anything to load.
+func secretHashAnnotations(ctx context.Context, c client.Reader, namespace, key, certSecret string,
+	settings mtlsSettings) (map[string]string, error) {
+	if !settings.restartOnRotation {
+		return map[string]string{key: ""}, nil
+	}
+	hash, err := secretsHash(ctx, c, namespace, certSecret, caCertSecretName)
+	if err != nil || hash == "" {
+		return nil, err
+	}
+	return map[string]string{key: hash}, nil
+}
+
+// setTemplateAnnotations sets annotations on template, removing those with an empty
+// value. It reports whether template was changed.
+func setTemplateAnnotations(template *corev1.PodTemplateSpec, annotations map[string]string) bool {
+	changed := false
+	for key, value := range annotations {
+		current, ok := template.Annotations[key]
+		switch {
+		case value == "" && ok:
+			delete(template.Annotations, key)
+			changed = true
+		case value != "" && current != value:
+			if template.Annotations == nil {
+				template.Annotations = map[string]string{}
+			}
+			template.Annotations[key] = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// hashRotated reports whether annotations replace a secret hash already stamped on the
+// pod template of w, which rolls its Pods.
+func hashRotated(w workload, annotations map[string]string) bool {
+	template := podTemplate(w.Object)
+	for key, value := range annotations {
+		if current := template.Annotations[key]; current != "" && value != "" && current != value {
+			return true
+		}
+	}
+	return false
+}
+
+// servicesForSecret maps a Service certificate Secret to its Service, and a CA copy to
+// the Services in its namespace that restart their workloads on rotation.
+func (r *AutomtlsReconciler) servicesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != caCertSecretName {
+		return serviceForCertificate(ctx, obj)
+	}
+
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, svc := range svcList.Items {
+		if _, ok := svc.Annotations[restartOnRotationAnnotation]; ok {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&svc)})
+		}
+	}
+	return requests
+}
+
+// identitiesForSecret maps an identity certificate Secret to its MTLSIdentity, and a
+// CA copy to the identities in its namespace that restart their workloads on rotation.
+func (r *MTLSIdentityReconciler) identitiesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != caCertSecretName {
+		namespace, name, ok := strings.Cut(strings.TrimPrefix(obj.GetAnnotations()[generatedForAnnotation], "MTLSIdentity/"), "/")
+		if !ok || namespace != obj.GetNamespace() || obj.GetName() != identitySecretName(name) {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+	}
+
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := r.List(ctx, &identityList, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, identity := range identityList.Items {
+		if identity.Spec.RestartOnRotation {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&identity)})
+		}
+	}
+	return requests
+}
+
+// stampSecretHash sets annotations on the pod templates of workloads without touching
+// their volumes, for the Webhook mount mode. obj is the Service or MTLSIdentity the
+// Secrets are mounted for, source names it in the events.
+func stampSecretHash(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object,
+	source string, workloads []workload, annotations map[string]string, log logr.Logger) error {
+	for _, w := range workloads {
+		if w.kind == "Job" {
+			continue
+		}
+		rotated := hashRotated(w, annotations)
+		patched, err := patchPodTemplate(ctx, c, w, func(template *corev1.PodTemplateSpec) (bool, error) {
+			return setTemplateAnnotations(template, annotations), nil
+		})
+		if err != nil {
+			recorder.Eventf(obj, corev1.EventTypeWarning, "PatchFailed", "Failed to stamp the secret hash on %s: %v", w.String(), err)
+			return fmt.Errorf("%s: %w", w.String(), err)
+		}
+		if patched {
+			workloadPatches.WithLabelValues(w.GetNamespace()).Inc()
+			log.Info("Updated secret hash on workload", "workload", w.String(), "for", source)
+		}
+		if patched && rotated {
+			recordRollout(recorder, obj, source, w)
+		}
+	}
+	return nil
+}
+
+// recordRollout records that w rolls its Pods because the Secrets mounted for obj,
+// named source in the events, changed.
+func recordRollout(recorder record.EventRecorder, obj client.Object, source string, w workload) {
+	recorder.Eventf(obj, corev1.EventTypeNormal, "RolloutTriggered",
+		"Restarting %s, the certificate or CA changed", w.String())
+	recorder.Eventf(w.Object, corev1.EventTypeNormal, "RolloutTriggered",
+		"Restarting the Pods, the certificate or CA of %s changed", source)
+}
@@ -18,36 +18,51 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
+	"slices"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 
 	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
-	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
 )
 
 // AutomtlsReconciler reconciles a Automtls object
 type AutomtlsReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=services/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets;daemonsets;replicasets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=automtls.kupher.io,resources=automtlsconfigs,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,188 +76,404 @@ type AutomtlsReconciler struct {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AutomtlsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	managedState.client = mgr.GetClient()
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &corev1.Service{},
+		serviceSelectorIndex, indexServiceSelector); err != nil {
+		return err
+	}
+
+	// Workloads are reconciled through the Services selecting them. Only spec changes
+	// matter, status updates of busy workloads would flood the queue.
+	workloadHandler := handler.EnqueueRequestsFromMapFunc(r.servicesForWorkload)
+	workloadPredicates := builder.WithPredicates(predicate.GenerationChangedPredicate{})
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Service{}).
-		WithEventFilter(predicate.NewPredicateFuncs(func(obj client.Object) bool {
-			return obj.GetAnnotations()["auto-mtls.kupher.io/enabled"] == "true"
-		})).
+		For(&corev1.Service{}, builder.WithPredicates(predicate.NewPredicateFuncs(r.isMTLSService))).
+		Watches(&automtlsv1alpha1.Automtls{}, handler.EnqueueRequestsFromMapFunc(r.servicesForPolicy),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&automtlsv1alpha1.AutoMTLSConfig{}, handler.EnqueueRequestsFromMapFunc(r.servicesForConfig),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&certmanagerv1.Certificate{}, handler.EnqueueRequestsFromMapFunc(serviceForCertificate)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.servicesForSecret)).
+		Watches(&appsv1.Deployment{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.StatefulSet{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.DaemonSet{}, workloadHandler, workloadPredicates).
+		Watches(&appsv1.ReplicaSet{}, workloadHandler, workloadPredicates).
+		Watches(&batchv1.Job{}, workloadHandler, workloadPredicates).
+		Watches(&batchv1.CronJob{}, workloadHandler, workloadPredicates).
 		Complete(r)
 }
 
-// 1. Get service with specific annoation
+// 1. Get service with specific annoation or selected by an Automtls policy
 // 2. Create a Cert , which intern create secret
-// 3. Once secret created, patch deployment to mount tls secret
+// 3. Once secret created, patch the selected workload to mount tls secret
 
 func (r *AutomtlsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 
-	log.Info("Reconciling auto-mtls", "name", req.Name, "namespace", req.Namespace)
+	log.V(1).Info("Reconciling auto-mtls", "name", req.Name, "namespace", req.Namespace)
 	svc := &corev1.Service{}
 
 	if err := r.Get(ctx, req.NamespacedName, svc); err != nil {
 		if apierrors.IsNotFound(err) {
-			// Service is deleted → delete the certificate
-			certName := req.Name + "-cert"
-			err := r.Delete(ctx, &certmanagerv1.Certificate{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      certName,
-					Namespace: req.Namespace,
-				},
-			})
-			if err != nil && !apierrors.IsNotFound(err) {
+			// Service is gone, its objects were cleaned up by the finalizer and the
+			// garbage collector
+			if err := r.pruneServiceStatus(ctx, req.Namespace); err != nil {
+				log.Error(err, "Failed to drop deleted service from Automtls status", "namespace", req.Namespace)
 				return ctrl.Result{}, err
 			}
-			log.Info("Deleted certificate because service was deleted", "namespace", req.Namespace, "secret", certName)
-			// certificate is deleted → delete the secret
-			secretName := req.Name + "-cert-tls"
-
-			err = r.Delete(ctx, &corev1.Secret{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      secretName,
-					Namespace: req.Namespace,
-				},
-			})
-
-			if err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if !svc.DeletionTimestamp.IsZero() {
+		// Service is being deleted → undo everything done for it
+		if err := r.teardownService(ctx, svc, log); err != nil {
+			log.Error(err, "Failed to clean up after deleted service", "service", svc.Name)
+			r.Recorder.Eventf(svc, corev1.EventTypeWarning, "CleanupFailed", "Failed to clean up mTLS: %v", err)
+			reconcileFailures.WithLabelValues(svc.Namespace).Inc()
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	policy, err := policyForService(ctx, r.Client, svc)
+	if err != nil {
+		log.Error(err, "Failed to look up Automtls policy for service", "service", svc.Name)
+		return ctrl.Result{}, err
+	}
+	if !mtlsEnabled(svc, policy) {
+		if controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+			// mTLS was switched off → undo everything done for the service
+			log.Info("mTLS disabled for service, tearing down", "service", svc.Name)
+			if err := r.teardownService(ctx, svc, log); err != nil {
+				log.Error(err, "Failed to tear down mTLS for service", "service", svc.Name)
+				r.Recorder.Eventf(svc, corev1.EventTypeWarning, "CleanupFailed", "Failed to tear down mTLS: %v", err)
+				reconcileFailures.WithLabelValues(svc.Namespace).Inc()
 				return ctrl.Result{}, err
 			}
-			log.Info("Deleted secret because service was deleted", "namespace", req.Namespace, "secret", secretName)
 			return ctrl.Result{}, nil
 		}
+		log.V(1).Info("mTLS not enabled for service, skipping", "service", svc.Name)
+		return ctrl.Result{}, nil
+	}
+
+	config, err := loadClusterConfig(ctx, r.Client)
+	if err != nil {
+		log.Error(err, "Failed to load AutoMTLSConfig")
+		return ctrl.Result{}, err
+	}
+
+	status := serviceStatusFor(svc)
+	settings, err := resolveMTLSSettings(config, policy, svc)
+	if err != nil {
+		// The annotations need fixing by the user, retrying won't help
+		log.Error(err, "Invalid mTLS settings for service", "service", svc.Name)
+		r.Recorder.Event(svc, corev1.EventTypeWarning, "InvalidSettings", err.Error())
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "InvalidSettings", err.Error())
+		status.LastError = err.Error()
+		if statusErr := r.updateServiceStatus(ctx, svc, policy, status); statusErr != nil {
+			return ctrl.Result{}, statusErr
+		}
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+
+	if err := r.addFinalizer(ctx, svc); err != nil {
+		log.Error(err, "Failed to add cleanup finalizer to service", "service", svc.Name)
 		return ctrl.Result{}, err
 	}
 
-	err := r.enablemTLS(ctx, svc, log)
+	err = r.enablemTLS(ctx, svc, settings, status, log)
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if statusErr := r.updateServiceStatus(ctx, svc, policy, status); statusErr != nil {
+		log.Error(statusErr, "Failed to update mTLS status for service", "service", svc.Name)
+		if err == nil {
+			return ctrl.Result{}, statusErr
+		}
+	}
 	if err != nil {
 		log.Error(err, "Failed to enable mTLS for service", "service", svc.Name)
+		reconcileFailures.WithLabelValues(svc.Namespace).Inc()
 		return ctrl.Result{}, err
 	}
 
 	return ctrl.Result{}, nil
 }
 
-func (r *AutomtlsReconciler) enablemTLS(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
+func (r *AutomtlsReconciler) enablemTLS(ctx context.Context, svc *corev1.Service, settings mtlsSettings,
+	status *automtlsv1alpha1.ServiceStatus, log logr.Logger) error {
 	// Implementation for enabling server TLS
 
 	// Create Server cert and corresponding TLS secret
-	if err := r.createServerCert(ctx, svc, log); err != nil {
+	if err := r.createServerCert(ctx, svc, settings, status, log); err != nil {
 		log.Error(err, "Failed to create certificate for service", "service", svc.Name)
 		return err
 	}
 
 	// Create CA cert TLS secret
-	if err := r.createCACertSecret(ctx, svc, log); err != nil {
+	if err := r.createCACertSecret(ctx, svc, settings, status, log); err != nil {
 		log.Error(err, "Failed to create CA cert secret for service", "service", svc.Name)
 		return err
 	}
 
+	// Render the allowed callers of the Service
+	if err := r.renderCallerPolicy(ctx, svc, settings, log); err != nil {
+		log.Error(err, "Failed to render caller policy for service", "service", svc.Name)
+		return err
+	}
+
 	//mount Ca Cert and Server keys
-	if err := r.mountMTLSCerts(ctx, svc, log); err != nil {
+	if err := r.mountMTLSCerts(ctx, svc, settings, status, log); err != nil {
 		log.Error(err, "Failed to create CA cert secret for service", "service", svc.Name)
 		return err
 	}
+
+	// The caller policy is required by the mounts, it goes once they are removed
+	if settings.allowedCallers == nil {
+		if err := r.deleteCallerPolicy(ctx, svc, log); err != nil {
+			log.Error(err, "Failed to delete caller policy for service", "service", svc.Name)
+			return err
+		}
+	}
 	log.Info("Successfully mounted mTLS certificates for service", "service", svc.Name)
 	return nil
 
 }
 
-func (r *AutomtlsReconciler) mountMTLSCerts(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
-	// Implementation for mounting mTLS certificates into the deployment
-	deploy, err := r.findDeploymentForSvc(ctx, svc)
+func (r *AutomtlsReconciler) mountMTLSCerts(ctx context.Context, svc *corev1.Service, settings mtlsSettings,
+	status *automtlsv1alpha1.ServiceStatus, log logr.Logger) error {
+	// Implementation for mounting mTLS certificates into the workloads
+	workloads, err := r.findWorkloadsForSvc(ctx, svc)
 	if err != nil {
-		log.Error(err, "Failed to find deployment for service", "service", svc.Name)
+		log.Error(err, "Failed to find workloads for service", "service", svc.Name)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
 		return err
 	}
-	if deploy == nil {
-		log.Info("No deployment found for service", "service", svc.Name)
-		return nil // Nothing to do if no deployment found
-	} else {
-		err = mountSecrets(ctx, r.Client, deploy, svc.Name)
+	status.Workloads = nil
 
-		if err != nil {
-			log.Error(err, "Failed to patch deployment with server certificate", "deployment", deploy.Name, "service", svc.Name)
-			return err
-		}
-
-		if err != nil {
-			log.Error(err, "Failed to mount server certificate", "service", svc.Name)
-			return err
-		}
-
-		log.Info("Successfully mounted server certificate to deployment", "deployment", deploy.Name, "service", svc.Name)
-		return nil
+	// The CA volume is shared with the other Services and identities on the same workload
+	services, identities, err := mtlsUsers(ctx, r.Client, svc.Namespace)
+	if err != nil {
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
+		return err
 	}
+	services = slices.DeleteFunc(services, func(other corev1.Service) bool { return other.Name == svc.Name })
 
-}
+	// Workloads the Service no longer selects lose its mounts
+	if err := r.unmountService(ctx, svc, workloads, services, identities, log); err != nil {
+		log.Error(err, "Failed to remove certificate mounts from unselected workloads", "service", svc.Name)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
+		return err
+	}
 
-func (r *AutomtlsReconciler) createCACertSecret(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
-	caCertSecret := &corev1.Secret{}
+	if len(workloads) == 0 {
+		log.Info("No workload found for service", "service", svc.Name)
+		message := "No workload matches the Service selector"
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "NoWorkload", message) {
+			r.Recorder.Event(svc, corev1.EventTypeWarning, "NoWorkload", message)
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "NoWorkload", message)
+		return nil // Nothing to do if no workload found
+	}
 
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      "auto-mtls-ca-cert",
-		Namespace: svc.Namespace,
-	}, caCertSecret)
+	annotations, err := secretHashAnnotations(ctx, r.Client, svc.Namespace, serviceHashAnnotation(svc.Name), svc.Name+"-cert-tls", settings)
+	if err != nil {
+		log.Error(err, "Failed to hash mounted secrets for service", "service", svc.Name)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "LookupFailed", err.Error())
+		return err
+	}
 
-	if err == nil {
-		// Secret already exists — skip
-		log.Info("Secret already exist, so skipping")
-		return nil
-	} else {
-		//Create secret for CA cert in namespace
-		src := &corev1.Secret{}
-		if err := r.Get(ctx, types.NamespacedName{
-			Name:      "auto-mtls-cluster-ca-cert-secret",
-			Namespace: "cert-manager",
-		}, src); err != nil {
-			log.Error(err, "failed to get source CA secret")
+	names := make([]string, 0, len(workloads))
+	for _, w := range workloads {
+		names = append(names, w.String())
+	}
+	if settings.mountMode == automtlsv1alpha1.MountModeWebhook {
+		// The Pod webhook adds the mounts when the Pods of the workloads are created,
+		// only the secret hash goes on the pod templates
+		if err := stampSecretHash(ctx, r.Client, r.Recorder, svc, "Service "+svc.Name, workloads, annotations, log); err != nil {
+			setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
 			return err
 		}
+		status.Workloads = names
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, true, "Webhook",
+			"New Pods of "+strings.Join(names, ", ")+" get the mounts from the admission webhook")
+		return nil
+	}
 
-		caData, ok := src.Data["ca.crt"]
-		if !ok {
-			log.Error(err, "Source secret missing ca.crt")
-			return fmt.Errorf("source secret missing ca.crt")
+	// The caller policy is dropped from the workloads once the allowed callers are unset
+	var stale []string
+	if settings.allowedCallers == nil {
+		stale = append(stale, callerPolicyName(svc.Name))
+	}
 
+	others := certVolumes(services, identities)
+
+	// Patch every workload, one failing must not keep the others from getting certificates
+	var immutable, conflicts []workload
+	var conflictErrs []string
+	var errs []error
+	for _, w := range workloads {
+		stamp := annotations
+		if w.kind == "Job" {
+			// The Pods of a Job are not rolled
+			stamp = nil
 		}
-		newSecret := &corev1.Secret{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "auto-mtls-ca-cert",
-				Namespace: svc.Namespace,
-			},
-			Data: map[string][]byte{
-				"ca.crt": caData,
-			},
-			Type: corev1.SecretTypeOpaque,
+		rotated := hashRotated(w, stamp)
+		patched, err := mountSecrets(ctx, r.Client, w, serviceMounts(svc.Name, settings), settings.containers,
+			others, stamp, stale...)
+		if errors.Is(err, errMountConflict) {
+			// Patching would take the mounts over from another Service or identity
+			log.Info("Cannot mount certificates into workload", "workload", w.String(), "service", svc.Name, "reason", err.Error())
+			conflicts = append(conflicts, w)
+			conflictErrs = append(conflictErrs, err.Error())
+			continue
+		}
+		if errors.Is(err, errImmutableTemplate) {
+			// Retrying won't help, the Job has to be recreated with the mounts
+			log.Info("Cannot mount certificates into workload with immutable pod template", "workload", w.String(), "service", svc.Name)
+			immutable = append(immutable, w)
+			continue
+		}
+		if err != nil {
+			log.Error(err, "Failed to patch workload with server certificate", "workload", w.String(), "service", svc.Name)
+			r.Recorder.Eventf(svc, corev1.EventTypeWarning, "PatchFailed", "Failed to mount certificates: %v", err)
+			r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "PatchFailed",
+				"Failed to mount the certificates of Service %s: %v", svc.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if patched {
+			r.Recorder.Eventf(svc, corev1.EventTypeNormal, "MountsPatched", "Mounted certificates into %s", w.String())
+			r.Recorder.Eventf(w.Object, corev1.EventTypeNormal, "MountsPatched",
+				"Mounted the certificates of Service %s at %s and %s", svc.Name, settings.certMountPath, settings.caMountPath)
+		}
+		if patched && rotated {
+			recordRollout(r.Recorder, svc, "Service "+svc.Name, w)
 		}
+		log.Info("Successfully mounted server certificate to workload", "workload", w.String(), "service", svc.Name)
+		status.Workloads = append(status.Workloads, w.String())
+	}
 
-		if err := r.Create(ctx, newSecret); err != nil {
-			return fmt.Errorf("failed to create secret in %s: %w", svc.Namespace, err)
+	switch {
+	case len(errs) > 0:
+		err := errors.Join(errs...)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "PatchFailed", err.Error())
+		return err
+	case len(conflicts) > 0:
+		message := strings.Join(conflictErrs, "; ")
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "MountConflict", message) {
+			for i, w := range conflicts {
+				r.Recorder.Eventf(svc, corev1.EventTypeWarning, "MountConflict", "Cannot mount certificates: %s", conflictErrs[i])
+				r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "MountConflict",
+					"Cannot mount the certificates of Service %s: %s", svc.Name, conflictErrs[i])
+			}
+		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "MountConflict", message)
+	case len(immutable) > 0:
+		message := "The pod template of " + workloadNames(immutable) + " cannot be patched"
+		if conditionChanges(status.Conditions, automtlsv1alpha1.ConditionMounted, false, "TemplateImmutable", message) {
+			for _, w := range immutable {
+				r.Recorder.Eventf(svc, corev1.EventTypeWarning, "TemplateImmutable",
+					"Cannot mount certificates into %s, its pod template is immutable", w.String())
+				r.Recorder.Eventf(w.Object, corev1.EventTypeWarning, "TemplateImmutable",
+					"Cannot mount the certificates of Service %s, the pod template is immutable", svc.Name)
+			}
 		}
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, false, "TemplateImmutable", message)
+	default:
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionMounted, true, "Mounted",
+			"Mounted into "+strings.Join(status.Workloads, ", "))
+	}
+	return nil
+}
+
+// workloadNames joins the names of workloads for condition messages.
+func workloadNames(workloads []workload) string {
+	names := make([]string, 0, len(workloads))
+	for _, w := range workloads {
+		names = append(names, w.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *AutomtlsReconciler) createCACertSecret(ctx context.Context, svc *corev1.Service, settings mtlsSettings,
+	status *automtlsv1alpha1.ServiceStatus, log logr.Logger) error {
+	return copyCA(ctx, r.Client, r.Recorder, svc, settings, &status.Conditions, log)
+}
+
+// copyCA copies the CA certificate of settings.caSource into the namespace of obj,
+// recording the outcome in conditions and as events on obj.
+func copyCA(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object,
+	settings mtlsSettings, conditions *[]metav1.Condition, log logr.Logger) error {
+	namespace := obj.GetNamespace()
+	src := &corev1.Secret{}
+	if err := c.Get(ctx, settings.caSource, src); err != nil {
+		log.Error(err, "failed to get source CA secret", "secret", settings.caSource)
+		recorder.Eventf(obj, corev1.EventTypeWarning, "CACopyFailed", "Failed to read CA secret %s: %v", settings.caSource, err)
+		setCondition(conditions, automtlsv1alpha1.ConditionCACopied, false, "SourceUnavailable", err.Error())
+		return err
+	}
+
+	caData, ok := src.Data["ca.crt"]
+	if !ok {
+		log.Info("Source secret missing ca.crt", "secret", settings.caSource)
+		setCondition(conditions, automtlsv1alpha1.ConditionCACopied, false, "SourceUnavailable", "source secret missing ca.crt")
+		return fmt.Errorf("source secret missing ca.crt")
+	}
 
-		fmt.Println("Created CA secret in", svc.Namespace)
+	// Create or refresh the secret for CA cert in namespace, the CA bundle controller
+	// keeps it in sync afterwards
+	op, err := syncCACopy(ctx, c, namespace, caData)
+	if errors.Is(err, errNotManaged) {
+		log.Info("CA secret exists but was not created by auto-mtls", "namespace", namespace)
+		recorder.Eventf(obj, corev1.EventTypeWarning, "CACopyFailed",
+			"Secret %s exists and was not created by auto-mtls", caCertSecretName)
+		setCondition(conditions, automtlsv1alpha1.ConditionCACopied, false, "Conflict",
+			caCertSecretName+" exists in "+namespace+" and was not created by auto-mtls")
+		return fmt.Errorf("secret %s: %w", caCertSecretName, err)
+	}
+	if err != nil {
+		recorder.Eventf(obj, corev1.EventTypeWarning, "CACopyFailed", "Failed to copy the CA certificate: %v", err)
+		setCondition(conditions, automtlsv1alpha1.ConditionCACopied, false, "CopyFailed", err.Error())
+		return fmt.Errorf("failed to create secret in %s: %w", namespace, err)
+	}
 
+	if op != controllerutil.OperationResultNone {
+		caSecretCopies.WithLabelValues(namespace).Inc()
+		recorder.Eventf(obj, corev1.EventTypeNormal, "CACopied", "Copied the CA certificate into %s", caCertSecretName)
 	}
+	log.Info("Reconciled CA secret", "namespace", namespace, "operation", op)
+	setCondition(conditions, automtlsv1alpha1.ConditionCACopied, true, "Copied", "Copied auto-mtls-ca-cert into "+namespace)
 	return nil
 }
 
-func (r *AutomtlsReconciler) findDeploymentForSvc(ctx context.Context, svc *corev1.Service) (*appsv1.Deployment, error) {
-	// List all Services in the Deployment's namespace
-	var deployList appsv1.DeploymentList
-	if err := r.List(ctx, &deployList, client.InNamespace(svc.Namespace)); err != nil {
-		return nil, err
+// findWorkloadsForSvc returns every workload whose pod template is selected by svc.
+func (r *AutomtlsReconciler) findWorkloadsForSvc(ctx context.Context, svc *corev1.Service) ([]workload, error) {
+	// A Service without selector has its endpoints managed by hand
+	if len(svc.Spec.Selector) == 0 {
+		return nil, nil
 	}
 
-	// Get labels from Deployment's Pod template
-	svcLabels := svc.Spec.Selector
+	// List all workloads in the Service's namespace
+	workloads, err := listWorkloads(ctx, r.Client, svc.Namespace)
+	if err != nil {
+		return nil, err
+	}
 
-	// Find matching Service
-	for _, deploy := range deployList.Items {
-		if selectorMatches(svcLabels, deploy.Spec.Template.Labels) {
-			return &deploy, nil
+	// Find matching workloads
+	var matched []workload
+	for _, w := range workloads {
+		if selectorMatches(podTemplate(w.Object).Labels, svc.Spec.Selector) {
+			matched = append(matched, w)
 		}
 	}
 
-	return nil, nil
+	return matched, nil
 }
 
 // helper: check if all selector key/values exist in labels
@@ -255,24 +486,29 @@ func selectorMatches(labels, selector map[string]string) bool {
 	return true
 }
 
-func (r *AutomtlsReconciler) createServerCert(ctx context.Context, service *corev1.Service, log logr.Logger) error {
+func (r *AutomtlsReconciler) createServerCert(ctx context.Context, service *corev1.Service, settings mtlsSettings,
+	status *automtlsv1alpha1.ServiceStatus, log logr.Logger) error {
 	namespace := service.Namespace
 	svc := service.Name
-	caIssuer := "auto-mtls-cluster-ca-issuer"
 	certName := svc + "-cert"
 	secretName := certName + "-tls"
 
-	existingCert := &certmanagerv1.Certificate{}
-
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      certName,
-		Namespace: namespace,
-	}, existingCert)
-
-	if err == nil {
-		// Certificate already exists — nothing to do
-		log.Info("Certificate already exists", "name", certName, "namespace", namespace)
-		return nil
+	workloads, err := r.findWorkloadsForSvc(ctx, service)
+	if err != nil {
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "CreateFailed", err.Error())
+		return err
+	}
+	id, err := spiffeID(settings.trustDomain, namespace, workloads)
+	if err != nil {
+		// Retrying won't help until the workloads agree on a service account
+		log.Info("Refusing to issue certificate for several identities", "service", svc, "reason", err.Error())
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "AmbiguousIdentity",
+			"The selected workloads must run as a single service account: "+err.Error())
+		return reconcile.TerminalError(err)
+	}
+	var uris []string
+	if id != "" {
+		uris = []string{id}
 	}
 
 	cert := &certmanagerv1.Certificate{
@@ -280,130 +516,98 @@ func (r *AutomtlsReconciler) createServerCert(ctx context.Context, service *core
 			Name:      certName,
 			Namespace: namespace,
 		},
-		Spec: certmanagerv1.CertificateSpec{
-			SecretName:  secretName,
-			Duration:    &metav1.Duration{Duration: 8760 * time.Hour}, // 1 year
-			RenewBefore: &metav1.Duration{Duration: 720 * time.Hour},  // 30 days
-			CommonName:  svc + "." + namespace + ".svc.cluster.local",
-			DNSNames: []string{
+	}
+
+	// Create the certificate, or bring an existing one in line with the current settings
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cert, func() error {
+		if !cert.CreationTimestamp.IsZero() && !isManagedCertificate(cert, service) {
+			if !isLegacyCertificate(cert, service) {
+				return errNotManaged
+			}
+			// Adopted below, from then on the annotation and owner reference identify it
+			log.Info("Adopting certificate created by an earlier version", "name", certName, "service", svc)
+		}
+		// The Service owns the certificate so it is garbage collected with the Service
+		if err := controllerutil.SetControllerReference(service, cert, r.Scheme); err != nil {
+			return err
+		}
+		if cert.Annotations == nil {
+			cert.Annotations = map[string]string{}
+		}
+		cert.Annotations[generatedForAnnotation] = namespace + "/" + svc
+
+		cert.Spec.SecretName = secretName
+		cert.Spec.Duration = &metav1.Duration{Duration: settings.duration}
+		cert.Spec.RenewBefore = &metav1.Duration{Duration: settings.renewBefore}
+		cert.Spec.CommonName = svc + "." + namespace + ".svc.cluster.local"
+		cert.Spec.DNSNames = nil
+		if settings.mode != modeClient {
+			// A client certificate is not served, the Service names would be meaningless
+			cert.Spec.DNSNames = []string{
 				svc,
 				svc + "." + namespace,
 				svc + "." + namespace + ".svc",
 				svc + "." + namespace + ".svc.cluster.local",
+			}
+		}
+		cert.Spec.URIs = uris
+		cert.Spec.Usages = certificateUsages(settings.mode)
+		cert.Spec.IssuerRef = settings.issuerRef
+		cert.Spec.PrivateKey = settings.privateKey
+		cert.Spec.SecretTemplate = &certmanagerv1.CertificateSecretTemplate{
+			Annotations: map[string]string{
+				generatedForAnnotation: namespace + "/" + svc,
 			},
-			IssuerRef: certmanagermetav1.ObjectReference{
-				Name: caIssuer,
-				Kind: "ClusterIssuer",
-			},
-			SecretTemplate: &certmanagerv1.CertificateSecretTemplate{
-				Annotations: map[string]string{
-					"auto-mtls.kupher.io/generated-for": namespace + "/" + svc,
-				},
-			},
-		},
+		}
+		return nil
+	})
+	if errors.Is(err, errNotManaged) {
+		// Never take over a certificate someone else created
+		log.Info("Certificate exists but was not created for service", "name", certName, "service", svc)
+		r.Recorder.Eventf(service, corev1.EventTypeWarning, "CertificateConflict",
+			"Certificate %s exists and was not created by auto-mtls", certName)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "Conflict",
+			"Certificate "+certName+" exists and was not created by auto-mtls")
+		return fmt.Errorf("certificate %s: %w", certName, err)
 	}
-	err = r.Create(ctx, cert)
 	if err != nil {
 		log.Error(err, "Failed to create certificate", "name", certName, "namespace", namespace)
+		r.Recorder.Eventf(service, corev1.EventTypeWarning, "CertificateFailed", "Failed to create Certificate %s: %v", certName, err)
+		setCondition(&status.Conditions, automtlsv1alpha1.ConditionCertificateReady, false, "CreateFailed", err.Error())
 		return err
 	}
-	log.Info("Created certificate", "name", certName, "namespace", namespace)
+	switch op {
+	case controllerutil.OperationResultCreated:
+		certificatesCreated.WithLabelValues(namespace).Inc()
+		r.Recorder.Eventf(service, corev1.EventTypeNormal, "CertificateCreated", "Created Certificate %s", certName)
+	case controllerutil.OperationResultUpdated:
+		r.Recorder.Eventf(service, corev1.EventTypeNormal, "CertificateUpdated", "Updated Certificate %s", certName)
+	}
+	log.Info("Reconciled certificate", "name", certName, "namespace", namespace, "operation", op)
+	setCertificateStatus(status, cert)
 	return nil
 }
 
-// patchDeployment adds a volume and volumeMount if missing
-func mountSecrets(ctx context.Context, c client.Client, deploy *appsv1.Deployment, svcName string) error {
-	serverCertvolumeName := svcName + "-cert-tls"
-	patched := deploy.DeepCopy()
-
-	// Check if foundServerCertVol exists, if not append
-	foundServerCertVol := false
-	for _, v := range patched.Spec.Template.Spec.Volumes {
-		if v.Name == serverCertvolumeName {
-			fmt.Println("Skipping auto-mtls-cert volume to deployment", "deployment", deploy.Name)
-			foundServerCertVol = true
-		}
-	}
-	if !foundServerCertVol {
-		patched.Spec.Template.Spec.Volumes = append(patched.Spec.Template.Spec.Volumes,
-			corev1.Volume{
-				Name: serverCertvolumeName,
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName: serverCertvolumeName, // Secret name spacific to service
-						Optional:   ptrBool(true),
-					},
-				},
-			},
-		)
-	}
-
-	// Add volumeMount to each container if missing
-	for i, container := range patched.Spec.Template.Spec.Containers {
-		foundMount := false
-		for _, vm := range container.VolumeMounts {
-			if vm.Name == serverCertvolumeName {
-				foundMount = true
-				break
-			}
-		}
-		if !foundMount {
-			patched.Spec.Template.Spec.Containers[i].VolumeMounts = append(container.VolumeMounts,
-				corev1.VolumeMount{
-					Name:      serverCertvolumeName,
-					MountPath: "/etc/tls",
-					ReadOnly:  true,
-				},
-			)
-		}
-	}
-
-	caCertvolumeName := "auto-mtls-ca-cert"
-	// Check if foundServerCertVol exists, if not append
-	caCertVol := false
-	for _, v := range patched.Spec.Template.Spec.Volumes {
-		if v.Name == caCertvolumeName {
-			fmt.Println("Skipping auto-mtls-cert volume to deployment", "deployment", deploy.Name)
-			caCertVol = true
+// mountSecrets adds the volumes of mounts to the workload and mounts them in the
+// targeted containers, sets the pod template annotations and drops the stale volumes,
+// patching only when something changed. others share the CA volume, see applyMounts
+func mountSecrets(ctx context.Context, c client.Client, w workload, mounts []secretMount, containers []string,
+	others []string, annotations map[string]string, stale ...string) (bool, error) {
+	patched, err := patchPodTemplate(ctx, c, w, func(template *corev1.PodTemplateSpec) (bool, error) {
+		changed, err := applyMounts(&template.Spec, mounts, containers, others)
+		if err != nil {
+			return false, err
 		}
+		changed = setTemplateAnnotations(template, annotations) || changed
+		return removeMounts(&template.Spec, stale...) || changed, nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", w.String(), err)
 	}
-	if !caCertVol {
-		patched.Spec.Template.Spec.Volumes = append(patched.Spec.Template.Spec.Volumes,
-			corev1.Volume{
-				Name: caCertvolumeName,
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName: caCertvolumeName, // Secret name spacific to service
-						Optional:   ptrBool(true),
-					},
-				},
-			},
-		)
-	}
-
-	// Add volumeMount to each container if missing
-	for i, container := range patched.Spec.Template.Spec.Containers {
-		foundMount := false
-		for _, vm := range container.VolumeMounts {
-			if vm.Name == caCertvolumeName {
-				foundMount = true
-				break
-			}
-		}
-		if !foundMount {
-			patched.Spec.Template.Spec.Containers[i].VolumeMounts = append(container.VolumeMounts,
-				corev1.VolumeMount{
-					Name:      caCertvolumeName,
-					MountPath: "/etc/ca",
-					ReadOnly:  true,
-				},
-			)
-		}
+	if patched {
+		workloadPatches.WithLabelValues(w.GetNamespace()).Inc()
 	}
-
-	// Patch the deployment
-
-	return c.Patch(ctx, patched, client.MergeFrom(deploy))
+	return patched, nil
 }
 
 // ptrBool returns a pointer to the given bool value.
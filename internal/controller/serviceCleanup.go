@@ -0,0 +1,268 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// errNotManaged is returned when an object the operator would create already exists
+// and was not created by the operator.
+var errNotManaged = errors.New("exists and is not managed by auto-mtls")
+
+// serviceFinalizer keeps a managed Service around until the operator has removed
+// the objects it created for it.
+const serviceFinalizer = "auto-mtls.kupher.io/cleanup"
+
+// generatedFor reports whether obj was created by the operator for svc.
+func generatedFor(obj client.Object, svc *corev1.Service) bool {
+	return obj.GetAnnotations()[generatedForAnnotation] == svc.Namespace+"/"+svc.Name
+}
+
+// isManagedCertificate reports whether cert was created by the operator for svc.
+func isManagedCertificate(cert *certmanagerv1.Certificate, svc *corev1.Service) bool {
+	return metav1.IsControlledBy(cert, svc) || generatedFor(cert, svc)
+}
+
+// isLegacyCertificate reports whether cert was created for svc by an operator version
+// that only annotated the Secret template. Such certificates are adopted once when
+// the Service is reconciled, and are then recognised by isManagedCertificate.
+func isLegacyCertificate(cert *certmanagerv1.Certificate, svc *corev1.Service) bool {
+	_, annotated := cert.GetAnnotations()[generatedForAnnotation]
+	return !annotated && len(cert.OwnerReferences) == 0 && cert.Spec.SecretTemplate != nil &&
+		cert.Spec.SecretTemplate.Annotations[generatedForAnnotation] == svc.Namespace+"/"+svc.Name
+}
+
+// cleanupService deletes the Certificate, Secret and caller policy the operator created
+// for svc. Objects with the same names that the operator did not create are left alone.
+func (r *AutomtlsReconciler) cleanupService(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
+	certName := svc.Name + "-cert"
+	cert := &certmanagerv1.Certificate{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: svc.Namespace, Name: certName}, cert)
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return err
+	case !isManagedCertificate(cert, svc):
+		log.Info("Leaving certificate not created for service", "name", certName, "service", svc.Name)
+	default:
+		if err := r.Delete(ctx, cert); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.Info("Deleted certificate of service", "name", certName, "service", svc.Name)
+	}
+
+	secretName := certName + "-tls"
+	secret := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: svc.Namespace, Name: secretName}, secret)
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return err
+	case !generatedFor(secret, svc):
+		log.Info("Leaving secret not created for service", "name", secretName, "service", svc.Name)
+	default:
+		if err := r.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.Info("Deleted secret of service", "name", secretName, "service", svc.Name)
+	}
+	return r.deleteCallerPolicy(ctx, svc, log)
+}
+
+// teardownService reverses enablemTLS for a Service that is deleted or no longer has
+// mTLS enabled: the mounts are removed from the workloads, the Certificate, Secret and
+// unused CA copy are deleted, and the Service is released.
+func (r *AutomtlsReconciler) teardownService(ctx context.Context, svc *corev1.Service, log logr.Logger) error {
+	if !controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+		return nil
+	}
+
+	services, identities, err := mtlsUsers(ctx, r.Client, svc.Namespace)
+	if err != nil {
+		return err
+	}
+	// Other Services still managed in the namespace
+	others := slices.DeleteFunc(services, func(other corev1.Service) bool { return other.Name == svc.Name })
+
+	if err := r.unmountService(ctx, svc, nil, others, identities, log); err != nil {
+		return err
+	}
+	if err := r.cleanupService(ctx, svc, log); err != nil {
+		return err
+	}
+	if len(others) == 0 && len(identities) == 0 {
+		if err := deleteCACopy(ctx, r.Client, svc.Namespace, log); err != nil {
+			return err
+		}
+	}
+
+	patched := svc.DeepCopy()
+	delete(patched.Annotations, statusAnnotation)
+	controllerutil.RemoveFinalizer(patched, serviceFinalizer)
+	err = r.Patch(ctx, patched, client.MergeFromWithOptions(svc, client.MergeFromWithOptimisticLock{}))
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	log.Info("Tore down mTLS for service", "service", svc.Name)
+	r.Recorder.Event(svc, corev1.EventTypeNormal, "CleanupDone",
+		"Removed the certificate mounts, Certificate and Secret created for this Service")
+	return r.pruneServiceStatus(ctx, svc.Namespace)
+}
+
+// unmountService removes the certificate volume and secret hash of svc from the
+// workloads carrying them, except from keep, and the CA volume too unless one of the
+// others Services or the identities still uses it on the workload.
+func (r *AutomtlsReconciler) unmountService(ctx context.Context, svc *corev1.Service, keep []workload,
+	others []corev1.Service, identities []automtlsv1alpha1.MTLSIdentity, log logr.Logger) error {
+	workloads, err := listWorkloads(ctx, r.Client, svc.Namespace)
+	if err != nil {
+		return err
+	}
+
+	certVolume := svc.Name + "-cert-tls"
+	hashKey := serviceHashAnnotation(svc.Name)
+	for _, w := range workloads {
+		template := podTemplate(w.Object)
+		_, stamped := template.Annotations[hashKey]
+		if (!stamped && !hasSecretVolume(&template.Spec, certVolume)) || slices.ContainsFunc(keep, func(k workload) bool {
+			return k.String() == w.String()
+		}) {
+			continue
+		}
+		volumes := []string{certVolume, callerPolicyName(svc.Name)}
+		if !caStillUsed(template, others, identities) {
+			volumes = append(volumes, caCertSecretName)
+		}
+
+		patched, err := patchPodTemplate(ctx, r.Client, w, func(template *corev1.PodTemplateSpec) (bool, error) {
+			removed := setTemplateAnnotations(template, map[string]string{hashKey: ""})
+			return removeMounts(&template.Spec, volumes...) || removed, nil
+		})
+		if errors.Is(err, errImmutableTemplate) {
+			log.Info("Cannot remove certificate mounts from workload with immutable pod template", "workload", w.String(), "service", svc.Name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", w.String(), err)
+		}
+		if patched {
+			workloadPatches.WithLabelValues(svc.Namespace).Inc()
+			r.Recorder.Eventf(w.Object, corev1.EventTypeNormal, "MountsRemoved",
+				"Removed the certificate mounts of Service %s", svc.Name)
+		}
+		log.Info("Removed certificate mounts from workload", "workload", w.String(), "service", svc.Name)
+	}
+	return nil
+}
+
+// mtlsUsers returns the Services and MTLSIdentities in namespace the operator still
+// manages: those carrying the cleanup finalizer that are not being deleted.
+func mtlsUsers(ctx context.Context, c client.Reader, namespace string) ([]corev1.Service,
+	[]automtlsv1alpha1.MTLSIdentity, error) {
+	var svcList corev1.ServiceList
+	if err := c.List(ctx, &svcList, client.InNamespace(namespace)); err != nil {
+		return nil, nil, err
+	}
+	var services []corev1.Service
+	for _, svc := range svcList.Items {
+		if svc.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(&svc, serviceFinalizer) {
+			services = append(services, svc)
+		}
+	}
+
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := c.List(ctx, &identityList, client.InNamespace(namespace)); err != nil {
+		return nil, nil, err
+	}
+	var identities []automtlsv1alpha1.MTLSIdentity
+	for _, identity := range identityList.Items {
+		if identity.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(&identity, identityFinalizer) {
+			identities = append(identities, identity)
+		}
+	}
+	return services, identities, nil
+}
+
+// certVolumes returns the certificate volume names of services and identities.
+func certVolumes(services []corev1.Service, identities []automtlsv1alpha1.MTLSIdentity) []string {
+	names := make([]string, 0, len(services)+len(identities))
+	for _, svc := range services {
+		names = append(names, svc.Name+"-cert-tls")
+	}
+	for _, identity := range identities {
+		names = append(names, identitySecretName(identity.Name))
+	}
+	return names
+}
+
+// caStillUsed reports whether the CA volume on template is still needed after a
+// certificate volume is removed: one of the services selects it, or it carries the
+// certificate volume of one of the identities.
+func caStillUsed(template *corev1.PodTemplateSpec, services []corev1.Service,
+	identities []automtlsv1alpha1.MTLSIdentity) bool {
+	return slices.ContainsFunc(services, func(svc corev1.Service) bool {
+		return len(svc.Spec.Selector) > 0 && selectorMatches(template.Labels, svc.Spec.Selector)
+	}) || slices.ContainsFunc(identities, func(identity automtlsv1alpha1.MTLSIdentity) bool {
+		return hasSecretVolume(&template.Spec, identitySecretName(identity.Name))
+	})
+}
+
+// deleteCACopy deletes the CA copy in namespace if the operator created it.
+func deleteCACopy(ctx context.Context, c client.Client, namespace string, log logr.Logger) error {
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: caCertSecretName}, secret)
+	if err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !isManagedCACopy(secret) {
+		return nil
+	}
+	if err := c.Delete(ctx, secret); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	log.Info("Deleted unused CA certificate copy", "namespace", namespace)
+	return nil
+}
+
+// addFinalizer makes sure the operator gets to clean up before svc is deleted.
+func (r *AutomtlsReconciler) addFinalizer(ctx context.Context, svc *corev1.Service) error {
+	if controllerutil.ContainsFinalizer(svc, serviceFinalizer) {
+		return nil
+	}
+	patched := svc.DeepCopy()
+	controllerutil.AddFinalizer(patched, serviceFinalizer)
+	if err := r.Patch(ctx, patched, client.MergeFromWithOptions(svc, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	patched.DeepCopyInto(svc)
+	return nil
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+func TestCertificateOwnership(t *testing.T) {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", UID: "svc-uid"}}
+	legacyTemplate := &certmanagerv1.CertificateSecretTemplate{
+		Annotations: map[string]string{generatedForAnnotation: "shop/web"},
+	}
+
+	tests := []struct {
+		name        string
+		cert        certmanagerv1.Certificate
+		wantManaged bool
+		wantLegacy  bool
+	}{
+		{
+			name: "controlled by the service",
+			cert: certmanagerv1.Certificate{ObjectMeta: metav1.ObjectMeta{
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1", Kind: "Service", Name: "web", UID: "svc-uid", Controller: ptrBool(true),
+				}},
+			}},
+			wantManaged: true,
+		},
+		{
+			name: "annotated for the service",
+			cert: certmanagerv1.Certificate{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{generatedForAnnotation: "shop/web"},
+			}},
+			wantManaged: true,
+		},
+		{
+			name: "annotated for another service",
+			cert: certmanagerv1.Certificate{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{generatedForAnnotation: "shop/api"},
+			}, Spec: certmanagerv1.CertificateSpec{SecretTemplate: legacyTemplate}},
+		},
+		{
+			name: "created by an earlier version",
+			cert: certmanagerv1.Certificate{Spec: certmanagerv1.CertificateSpec{SecretTemplate: legacyTemplate}},
+			// Adopted at reconcile time only, never deleted on its shape alone
+			wantLegacy: true,
+		},
+		{
+			name: "same common name, created by someone else",
+			cert: certmanagerv1.Certificate{Spec: certmanagerv1.CertificateSpec{
+				CommonName: "web.shop.svc.cluster.local",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isManagedCertificate(&tt.cert, svc); got != tt.wantManaged {
+				t.Errorf("isManagedCertificate() = %v, want %v", got, tt.wantManaged)
+			}
+			if got := isLegacyCertificate(&tt.cert, svc); got != tt.wantLegacy {
+				t.Errorf("isLegacyCertificate() = %v, want %v", got, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestCACopyOwnership(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      corev1.Secret
+		wantManaged bool
+		wantLegacy  bool
+	}{
+		{
+			name: "labelled by the operator",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{managedByLabel: managedByValue}},
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{"ca.crt": []byte("ca")},
+			},
+			wantManaged: true,
+		},
+		{
+			name: "created by an earlier version",
+			secret: corev1.Secret{
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{"ca.crt": []byte("ca")},
+			},
+			wantLegacy: true,
+		},
+		{
+			name: "holds more than the CA",
+			secret: corev1.Secret{
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{"ca.crt": []byte("ca"), "token": []byte("t")},
+			},
+		},
+		{
+			name: "labelled by someone else",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Type:       corev1.SecretTypeOpaque,
+				Data:       map[string][]byte{"ca.crt": []byte("ca")},
+			},
+		},
+		{
+			name: "owned by another object",
+			secret: corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "v1", Kind: "ConfigMap", Name: "bundle", UID: "cm-uid",
+				}}},
+				Type: corev1.SecretTypeOpaque,
+				Data: map[string][]byte{"ca.crt": []byte("ca")},
+			},
+		},
+		{
+			name: "TLS secret",
+			secret: corev1.Secret{
+				Type: corev1.SecretTypeTLS,
+				Data: map[string][]byte{"ca.crt": []byte("ca")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isManagedCACopy(&tt.secret); got != tt.wantManaged {
+				t.Errorf("isManagedCACopy() = %v, want %v", got, tt.wantManaged)
+			}
+			if got := isLegacyCACopy(&tt.secret); got != tt.wantLegacy {
+				t.Errorf("isLegacyCACopy() = %v, want %v", got, tt.wantLegacy)
+			}
+		})
+	}
+}
+
+func TestUnmountServiceKeepsIdentityHash(t *testing.T) {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+	identity := automtlsv1alpha1.MTLSIdentity{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+				serviceHashAnnotation(svc.Name):       "sha256:service",
+				identityHashAnnotation(identity.Name): "sha256:identity",
+			}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		}},
+	}
+	mounts := append(serviceMounts(svc.Name, mtlsSettings{}), identityMounts(&identity, mtlsSettings{})...)
+	if _, err := applyMounts(&deployment.Spec.Template.Spec, mounts, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(deployment).Build()
+	r := &AutomtlsReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+	err := r.unmountService(context.Background(), svc, nil, nil, []automtlsv1alpha1.MTLSIdentity{identity}, logr.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(deployment), got); err != nil {
+		t.Fatal(err)
+	}
+	annotations := got.Spec.Template.Annotations
+	if _, ok := annotations[serviceHashAnnotation(svc.Name)]; ok {
+		t.Errorf("service hash still stamped: %v", annotations)
+	}
+	if annotations[identityHashAnnotation(identity.Name)] != "sha256:identity" {
+		t.Errorf("identity hash removed with the Service: %v", annotations)
+	}
+	if hasSecretVolume(&got.Spec.Template.Spec, svc.Name+"-cert-tls") {
+		t.Error("service certificate volume still mounted")
+	}
+	if !hasSecretVolume(&got.Spec.Template.Spec, identitySecretName(identity.Name)) ||
+		!hasSecretVolume(&got.Spec.Template.Spec, caCertSecretName) {
+		t.Error("identity mounts removed with the Service")
+	}
+}
+
+func TestMountMTLSCertsUnmountsUnselectedWorkloads(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Finalizers: []string{serviceFinalizer}},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	settings := defaultMTLSSettings(&automtlsv1alpha1.AutoMTLSConfigSpec{})
+	deployment := func(name, app string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": app}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+			}},
+		}
+	}
+	selected := deployment("web-v2", "web")
+	// Relabelled after the Service mounted its certificates
+	relabelled := deployment("web-v1", "web-old")
+	relabelled.Spec.Template.Annotations = map[string]string{serviceHashAnnotation(svc.Name): "sha256:old"}
+	if _, err := applyMounts(&relabelled.Spec.Template.Spec, serviceMounts(svc.Name, settings), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(svc, selected, relabelled).Build()
+	r := &AutomtlsReconciler{Client: c, Recorder: record.NewFakeRecorder(10)}
+	status := &automtlsv1alpha1.ServiceStatus{}
+	if err := r.mountMTLSCerts(context.Background(), svc, settings, status, logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &appsv1.Deployment{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(selected), got); err != nil {
+		t.Fatal(err)
+	}
+	if !hasSecretVolume(&got.Spec.Template.Spec, svc.Name+"-cert-tls") {
+		t.Error("selected workload did not get the certificate mount")
+	}
+
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(relabelled), got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Spec.Template.Spec.Volumes) > 0 || len(got.Spec.Template.Spec.Containers[0].VolumeMounts) > 0 {
+		t.Errorf("unselected workload still carries mounts: %v", got.Spec.Template.Spec.Volumes)
+	}
+	if _, ok := got.Spec.Template.Annotations[serviceHashAnnotation(svc.Name)]; ok {
+		t.Error("unselected workload still carries the secret hash")
+	}
+	if !slices.Equal(status.Workloads, []string{"Deployment/web-v2"}) {
+		t.Errorf("status.Workloads = %v", status.Workloads)
+	}
+}
+
+func TestMountedServices(t *testing.T) {
+	template := &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+			serviceHashAnnotation("api"):  "sha256:api",
+			identityHashAnnotation("job"): "sha256:job",
+		}},
+	}
+	if _, err := applyMounts(&template.Spec, serviceMounts("web", mtlsSettings{}), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	got := mountedServices(template)
+	slices.Sort(got)
+	if want := []string{"api", "web"}; !slices.Equal(got, want) {
+		t.Errorf("mountedServices() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// statusAnnotation holds the JSON encoded ServiceStatus of a managed Service.
+const statusAnnotation = "auto-mtls.kupher.io/status"
+
+// generatedForAnnotation links a Certificate or Secret back to its "<namespace>/<service>".
+const generatedForAnnotation = "auto-mtls.kupher.io/generated-for"
+
+// serviceStatusFor returns the status last recorded on svc, so condition transition
+// times survive across reconciles.
+func serviceStatusFor(svc *corev1.Service) *automtlsv1alpha1.ServiceStatus {
+	status := &automtlsv1alpha1.ServiceStatus{}
+	if raw, ok := svc.GetAnnotations()[statusAnnotation]; ok {
+		_ = json.Unmarshal([]byte(raw), status)
+	}
+	status.Name = svc.Name
+	return status
+}
+
+// setCondition records a step outcome in conditions.
+func setCondition(conditions *[]metav1.Condition, conditionType string, ok bool, reason, message string) {
+	conditionStatus := metav1.ConditionFalse
+	if ok {
+		conditionStatus = metav1.ConditionTrue
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    conditionType,
+		Status:  conditionStatus,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// conditionChanges reports whether setCondition with the same arguments would change
+// conditions. Warnings are only recorded on a change, not on every reconcile.
+func conditionChanges(conditions []metav1.Condition, conditionType string, ok bool, reason, message string) bool {
+	current := meta.FindStatusCondition(conditions, conditionType)
+	return current == nil || (current.Status == metav1.ConditionTrue) != ok ||
+		current.Reason != reason || current.Message != message
+}
+
+// setCertificateStatus copies the readiness and expiry of cert into status.
+func setCertificateStatus(status *automtlsv1alpha1.ServiceStatus, cert *certmanagerv1.Certificate) {
+	status.CertificateNotAfter = cert.Status.NotAfter
+	setCertificateCondition(&status.Conditions, cert)
+}
+
+// setCertificateCondition mirrors the Ready condition of cert in conditions.
+func setCertificateCondition(conditions *[]metav1.Condition, cert *certmanagerv1.Certificate) {
+	for _, cond := range cert.Status.Conditions {
+		if cond.Type == certmanagerv1.CertificateConditionReady {
+			setCondition(conditions, automtlsv1alpha1.ConditionCertificateReady,
+				cond.Status == certmanagermetav1.ConditionTrue, cond.Reason, cond.Message)
+			return
+		}
+	}
+	setCondition(conditions, automtlsv1alpha1.ConditionCertificateReady, false, "Pending",
+		"Certificate "+cert.Name+" has not been issued yet")
+}
+
+// setReadyCondition derives the Ready condition from the step conditions.
+func setReadyCondition(conditions *[]metav1.Condition) {
+	for _, conditionType := range []string{
+		automtlsv1alpha1.ConditionCertificateReady,
+		automtlsv1alpha1.ConditionCACopied,
+		automtlsv1alpha1.ConditionMounted,
+	} {
+		if !meta.IsStatusConditionTrue(*conditions, conditionType) {
+			setCondition(conditions, automtlsv1alpha1.ConditionReady, false, "NotReady", conditionType+" is not True")
+			return
+		}
+	}
+	setCondition(conditions, automtlsv1alpha1.ConditionReady, true, "Ready", "mTLS is enabled")
+}
+
+// updateServiceStatus writes status to the Service annotation and, when a policy
+// selects the Service, to the status of that Automtls object.
+func (r *AutomtlsReconciler) updateServiceStatus(ctx context.Context, svc *corev1.Service,
+	policy *automtlsv1alpha1.Automtls, status *automtlsv1alpha1.ServiceStatus) error {
+	setReadyCondition(&status.Conditions)
+
+	raw, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if svc.GetAnnotations()[statusAnnotation] != string(raw) {
+		patched := svc.DeepCopy()
+		if patched.Annotations == nil {
+			patched.Annotations = map[string]string{}
+		}
+		patched.Annotations[statusAnnotation] = string(raw)
+		if err := r.Patch(ctx, patched, client.MergeFrom(svc)); err != nil {
+			return err
+		}
+	}
+
+	if policy == nil {
+		return nil
+	}
+	return r.syncPolicyStatus(ctx, policy, status)
+}
+
+// syncPolicyStatus upserts current (if not nil) into the policy status and drops the
+// entries of Services that no longer exist or are no longer selected.
+func (r *AutomtlsReconciler) syncPolicyStatus(ctx context.Context, policy *automtlsv1alpha1.Automtls,
+	current *automtlsv1alpha1.ServiceStatus) error {
+	var svcList corev1.ServiceList
+	if err := r.List(ctx, &svcList, client.InNamespace(policy.Namespace)); err != nil {
+		return err
+	}
+	selected := map[string]bool{}
+	for _, svc := range svcList.Items {
+		selected[svc.Name] = policySelects(policy, &svc) && mtlsEnabled(&svc, policy)
+	}
+
+	services := []automtlsv1alpha1.ServiceStatus{}
+	for _, entry := range policy.Status.Services {
+		if current != nil && entry.Name == current.Name {
+			services = append(services, *current)
+			current = nil
+			continue
+		}
+		if selected[entry.Name] {
+			services = append(services, entry)
+		}
+	}
+	if current != nil {
+		services = append(services, *current)
+	}
+
+	if equality.Semantic.DeepEqual(services, policy.Status.Services) {
+		return nil
+	}
+	patched := policy.DeepCopy()
+	patched.Status.Services = services
+	return r.Status().Patch(ctx, patched, client.MergeFrom(policy))
+}
+
+// pruneServiceStatus drops a deleted Service from the status of every policy in its namespace.
+func (r *AutomtlsReconciler) pruneServiceStatus(ctx context.Context, namespace string) error {
+	var policyList automtlsv1alpha1.AutomtlsList
+	if err := r.List(ctx, &policyList, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	for i := range policyList.Items {
+		if err := r.syncPolicyStatus(ctx, &policyList.Items[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serviceForCertificate maps a Certificate created by the operator to its Service.
+func serviceForCertificate(_ context.Context, obj client.Object) []reconcile.Request {
+	namespace, name, ok := strings.Cut(obj.GetAnnotations()[generatedForAnnotation], "/")
+	if !ok || namespace != obj.GetNamespace() {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// errAmbiguousIdentity is returned when the workloads behind a certificate run as
+// several service accounts. An X509-SVID carries exactly one SPIFFE ID.
+var errAmbiguousIdentity = errors.New("workloads run as several service accounts")
+
+// spiffeID returns the SPIFFE ID of the service account the workloads run as,
+// spiffe://<trustDomain>/ns/<namespace>/sa/<serviceAccount>, or "" when there are no
+// workloads. Pods without a service account run as "default". It returns
+// errAmbiguousIdentity if the workloads run as more than one service account.
+func spiffeID(trustDomain, namespace string, workloads []workload) (string, error) {
+	accounts := map[string]bool{}
+	for _, w := range workloads {
+		template := podTemplate(w.Object)
+		if template == nil {
+			continue
+		}
+		serviceAccount := template.Spec.ServiceAccountName
+		if serviceAccount == "" {
+			serviceAccount = "default"
+		}
+		accounts[serviceAccount] = true
+	}
+	if len(accounts) > 1 {
+		names := make([]string, 0, len(accounts))
+		for name := range accounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("%w: %s", errAmbiguousIdentity, strings.Join(names, ", "))
+	}
+	for serviceAccount := range accounts {
+		return (&url.URL{
+			Scheme: "spiffe",
+			Host:   trustDomain,
+			Path:   path.Join("/ns", namespace, "sa", serviceAccount),
+		}).String(), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	automtlsv1alpha1 "github.com/kupher-tools/auto-mtls/api/v1alpha1"
+)
+
+// rotatedAtAnnotation records on the trust bundle Secret when the current CA replaced
+// the previous one. It is removed once the previous CA has been retired.
+const rotatedAtAnnotation = "auto-mtls.kupher.io/rotated-at"
+
+// publishedAtAnnotation records on the trust bundle Secret when its current content
+// had been pushed to every namespace copy.
+const publishedAtAnnotation = "auto-mtls.kupher.io/published-at"
+
+// trustBundle is the set of CA certificates trusted in the namespaces.
+type trustBundle struct {
+	// current are the certificates of the CA Secret.
+	current []*x509.Certificate
+	// previous are the certificates of CAs rotated out but still trusted.
+	previous []*x509.Certificate
+	// rotatedAt is when current replaced previous, zero when previous is empty.
+	rotatedAt time.Time
+}
+
+// pem returns the PEM encoded bundle, current certificates first.
+func (b trustBundle) pem() []byte {
+	var buf bytes.Buffer
+	for _, cert := range append(append([]*x509.Certificate{}, b.current...), b.previous...) {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+// retirable reports whether the overlap window of a rotation in progress has passed.
+// The previous certificates are only dropped once no Service certificate needs them.
+func (b trustBundle) retirable(overlap time.Duration, now time.Time) bool {
+	return len(b.previous) > 0 && !now.Before(b.rotatedAt.Add(overlap))
+}
+
+// nextTrustBundle merges the CA certificates in caData into the last published
+// bundle. Certificates of the last bundle that are no longer current become previous,
+// starting the overlap window. Expired certificates are dropped.
+func nextTrustBundle(caData []byte, published *corev1.Secret, now time.Time) (trustBundle, error) {
+	current, err := parseCertificates(caData)
+	if err != nil {
+		return trustBundle{}, err
+	}
+	if len(current) == 0 {
+		return trustBundle{}, fmt.Errorf("no CA certificate found")
+	}
+	bundle := trustBundle{current: current}
+	if published == nil {
+		return bundle, nil
+	}
+
+	// An unreadable bundle is replaced, not trusted
+	last, _ := parseCertificates(published.Data["ca.crt"])
+	rotated := false
+	for _, cert := range current {
+		rotated = rotated || !containsCertificate(last, cert)
+	}
+	for _, cert := range last {
+		if containsCertificate(current, cert) || !now.Before(cert.NotAfter) {
+			continue
+		}
+		bundle.previous = append(bundle.previous, cert)
+	}
+	if len(bundle.previous) == 0 {
+		return bundle, nil
+	}
+
+	// Keep the start of a rotation that is already in progress
+	bundle.rotatedAt = now
+	if t, err := time.Parse(time.RFC3339, published.Annotations[rotatedAtAnnotation]); err == nil && !rotated {
+		bundle.rotatedAt = t
+	}
+	return bundle, nil
+}
+
+// parseCertificates decodes the PEM CERTIFICATE blocks in data.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// earliestExpiry returns the first NotAfter of certs, which must not be empty.
+func earliestExpiry(certs []*x509.Certificate) time.Time {
+	expiry := certs[0].NotAfter
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	return expiry
+}
+
+// containsCertificate reports whether cert is in certs.
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+// issuedUnder reports whether the certificate chain in tlsData verifies against roots.
+func issuedUnder(tlsData []byte, roots []*x509.Certificate) bool {
+	chain, err := parseCertificates(tlsData)
+	if err != nil || len(chain) == 0 {
+		return false
+	}
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, root := range roots {
+		opts.Roots.AddCert(root)
+	}
+	for _, intermediate := range chain[1:] {
+		opts.Intermediates.AddCert(intermediate)
+	}
+	_, err = chain[0].Verify(opts)
+	return err == nil
+}
+
+// leavesToReissue returns every Service and identity certificate signed by the default
+// issuer that does not chain to the current CA yet, and those still naming a previous
+// default issuer. Certificates on an issuer named by an Automtls policy or an
+// MTLSIdentity are left alone.
+func (r *CABundleReconciler) leavesToReissue(ctx context.Context, config *automtlsv1alpha1.AutoMTLSConfigSpec,
+	current []*x509.Certificate) ([]*certmanagerv1.Certificate, error) {
+	var certList certmanagerv1.CertificateList
+	if err := r.List(ctx, &certList); err != nil {
+		return nil, err
+	}
+	custom, err := customIssuerRefs(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	issuerRef := serviceIssuerRef(config)
+	var pending []*certmanagerv1.Certificate
+	for i := range certList.Items {
+		cert := &certList.Items[i]
+		if _, ok := cert.Annotations[generatedForAnnotation]; !ok || custom[cert.Spec.IssuerRef] {
+			continue
+		}
+
+		var leaf []byte
+		if cert.Spec.IssuerRef == issuerRef {
+			secret := &corev1.Secret{}
+			err := r.Get(ctx, types.NamespacedName{Namespace: cert.Namespace, Name: cert.Spec.SecretName}, secret)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			leaf = secret.Data["tls.crt"]
+		}
+		if leafPending(cert, leaf, issuerRef, current) {
+			pending = append(pending, cert)
+		}
+	}
+	return pending, nil
+}
+
+// leafPending reports whether the managed certificate cert, whose Secret holds leaf,
+// has yet to move to the current CA: it names another issuer than the default
+// issuerRef, or its leaf does not chain to current.
+func leafPending(cert *certmanagerv1.Certificate, leaf []byte, issuerRef certmanagermetav1.ObjectReference,
+	current []*x509.Certificate) bool {
+	return cert.Spec.IssuerRef != issuerRef || !issuedUnder(leaf, current)
+}
+
+// customIssuerRefs returns the issuers named by Automtls policies and MTLSIdentities,
+// whose certificates do not follow the default issuer.
+func customIssuerRefs(ctx context.Context, c client.Reader) (map[certmanagermetav1.ObjectReference]bool, error) {
+	custom := map[certmanagermetav1.ObjectReference]bool{}
+	add := func(ref *automtlsv1alpha1.IssuerReference) {
+		if ref != nil {
+			custom[certmanagermetav1.ObjectReference{Name: ref.Name, Kind: ref.Kind, Group: ref.Group}] = true
+		}
+	}
+	var policyList automtlsv1alpha1.AutomtlsList
+	if err := c.List(ctx, &policyList); err != nil {
+		return nil, err
+	}
+	for _, policy := range policyList.Items {
+		add(policy.Spec.IssuerRef)
+	}
+	var identityList automtlsv1alpha1.MTLSIdentityList
+	if err := c.List(ctx, &identityList); err != nil {
+		return nil, err
+	}
+	for _, identity := range identityList.Items {
+		add(identity.Spec.IssuerRef)
+	}
+	return custom, nil
+}
+
+// reissueLeaves asks cert-manager to reissue every Service certificate that does not
+// chain to the current CA yet. Certificates still naming a previous issuer are moved
+// by their Service or identity reconcile instead. It reports whether all of them
+// already chain to the current CA.
+func (r *CABundleReconciler) reissueLeaves(ctx context.Context, config *automtlsv1alpha1.AutoMTLSConfigSpec,
+	current []*x509.Certificate, log logr.Logger) (bool, error) {
+	pending, err := r.leavesToReissue(ctx, config, current)
+	if err != nil {
+		return false, err
+	}
+	issuerRef := serviceIssuerRef(config)
+	for _, cert := range pending {
+		if cert.Spec.IssuerRef != issuerRef {
+			// Reissuing from the previous issuer would not help, the config watch
+			// brings the new issuer to the certificate
+			log.Info("Waiting for certificate to move to the new issuer", "name", cert.Name, "namespace", cert.Namespace)
+			continue
+		}
+		if cert.Status.Revision == nil || isIssuing(cert) {
+			// cert-manager is issuing it already
+			continue
+		}
+		if err := r.triggerIssuance(ctx, cert); err != nil {
+			return false, err
+		}
+		log.Info("Requested reissue of certificate under the new CA", "name", cert.Name, "namespace", cert.Namespace)
+	}
+	return len(pending) == 0, nil
+}
+
+// isIssuing reports whether cert-manager is issuing cert.
+func isIssuing(cert *certmanagerv1.Certificate) bool {
+	for _, cond := range cert.Status.Conditions {
+		if cond.Type == certmanagerv1.CertificateConditionIssuing {
+			return cond.Status == certmanagermetav1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// triggerIssuance sets the Issuing condition of cert, the same way cmctl renew does.
+func (r *CABundleReconciler) triggerIssuance(ctx context.Context, cert *certmanagerv1.Certificate) error {
+	patched := cert.DeepCopy()
+	now := metav1.Now()
+	conditions := patched.Status.Conditions[:0]
+	for _, cond := range patched.Status.Conditions {
+		if cond.Type != certmanagerv1.CertificateConditionIssuing {
+			conditions = append(conditions, cond)
+		}
+	}
+	patched.Status.Conditions = append(conditions, certmanagerv1.CertificateCondition{
+		Type:               certmanagerv1.CertificateConditionIssuing,
+		Status:             certmanagermetav1.ConditionTrue,
+		Reason:             "CARotated",
+		Message:            "Reissuing under the rotated auto-mtls CA",
+		LastTransitionTime: &now,
+		ObservedGeneration: cert.Generation,
+	})
+	return r.Status().Update(ctx, patched)
+}
+
+// errCASourceUnresolved is returned when the CA certificates to trust cannot be
+// found from the AutoMTLSConfig. Retrying won't help until the config or the issuer
+// changes.
+var errCASourceUnresolved = errors.New("cannot find the CA certificates to trust")
+
+// caSourceKey returns the Secret holding the CA certificates of the trust bundle and
+// the key to read. An empty key means ca.crt, falling back to tls.crt.
+func caSourceKey(ctx context.Context, c client.Reader, config *automtlsv1alpha1.AutoMTLSConfigSpec) (types.NamespacedName, string, error) {
+	if ref := config.CABundleSecretRef; ref != nil {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = config.CANamespace
+		}
+		key := ref.Key
+		if key == "" {
+			key = "ca.crt"
+		}
+		return types.NamespacedName{Namespace: namespace, Name: ref.Name}, key, nil
+	}
+	if config.IssuerRef == nil {
+		return types.NamespacedName{Namespace: config.CANamespace, Name: config.CASecretName}, "ca.crt", nil
+	}
+
+	// Without an explicit bundle, an external CA ClusterIssuer provides its own CA
+	ref := config.IssuerRef
+	if ref.Kind == "ClusterIssuer" && (ref.Group == "" || ref.Group == certmanagerv1.SchemeGroupVersion.Group) {
+		issuer := &certmanagerv1.ClusterIssuer{}
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, issuer); err != nil {
+			if apierrors.IsNotFound(err) {
+				return types.NamespacedName{}, "", fmt.Errorf("%w: ClusterIssuer %s does not exist", errCASourceUnresolved, ref.Name)
+			}
+			return types.NamespacedName{}, "", err
+		}
+		if issuer.Spec.CA != nil {
+			return types.NamespacedName{Namespace: config.CANamespace, Name: issuer.Spec.CA.SecretName}, "", nil
+		}
+	}
+	return types.NamespacedName{}, "", fmt.Errorf("%w: issuer %s %s is not a CA issuer, set caBundleSecretRef",
+		errCASourceUnresolved, ref.Kind, ref.Name)
+}
+
+// caSourceData returns the CA certificates stored under key in secret.
+func caSourceData(secret *corev1.Secret, key string) []byte {
+	if key != "" {
+		return secret.Data[key]
+	}
+	if data := secret.Data["ca.crt"]; len(data) > 0 {
+		return data
+	}
+	return secret.Data["tls.crt"]
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	certmanagerv1 "github.com/cert-manager/cert-manager/pkg/apis/certmanager/v1"
+	certmanagermetav1 "github.com/cert-manager/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testCA is a self-signed CA for the trust bundle tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string, notAfter time.Time) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             notAfter.Add(-10 * 365 * 24 * time.Hour),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert: cert, key: key}
+}
+
+// leafPEM returns a PEM encoded leaf certificate signed by ca.
+func (ca testCA) leafPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "svc.ns.svc.cluster.local"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func certsPEM(certs ...*x509.Certificate) []byte {
+	return trustBundle{current: certs}.pem()
+}
+
+func bundleSecret(data []byte, rotatedAt time.Time) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{}},
+		Data:       map[string][]byte{"ca.crt": data},
+	}
+	if !rotatedAt.IsZero() {
+		secret.Annotations[rotatedAtAnnotation] = rotatedAt.UTC().Format(time.RFC3339)
+	}
+	return secret
+}
+
+func TestNextTrustBundleFirstPublish(t *testing.T) {
+	now := time.Now()
+	ca := newTestCA(t, "ca", now.Add(24*time.Hour))
+
+	bundle, err := nextTrustBundle(certsPEM(ca.cert), nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.current) != 1 || !bundle.current[0].Equal(ca.cert) {
+		t.Errorf("current = %v, want the CA", bundle.current)
+	}
+	if len(bundle.previous) != 0 || !bundle.rotatedAt.IsZero() {
+		t.Errorf("previous = %v, rotatedAt = %v, want no rotation", bundle.previous, bundle.rotatedAt)
+	}
+	if bundle.retirable(time.Hour, now) {
+		t.Error("a bundle without previous CA is retirable")
+	}
+
+	// Publishing the same CA again does not start a rotation
+	bundle, err = nextTrustBundle(certsPEM(ca.cert), bundleSecret(bundle.pem(), time.Time{}), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.previous) != 0 {
+		t.Errorf("previous = %v after republishing the same CA", bundle.previous)
+	}
+}
+
+func TestNextTrustBundleInvalidCA(t *testing.T) {
+	if _, err := nextTrustBundle(nil, nil, time.Now()); err == nil {
+		t.Error("no error for an empty CA")
+	}
+	if _, err := nextTrustBundle([]byte("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n"), nil, time.Now()); err == nil {
+		t.Error("no error for a corrupt CA")
+	}
+}
+
+func TestNextTrustBundleRotation(t *testing.T) {
+	now := time.Now()
+	oldCA := newTestCA(t, "old", now.Add(24*time.Hour))
+	newCA := newTestCA(t, "new", now.Add(48*time.Hour))
+	overlap := 2 * time.Hour
+
+	// The renewed CA becomes current and the published one previous
+	bundle, err := nextTrustBundle(certsPEM(newCA.cert), bundleSecret(certsPEM(oldCA.cert), time.Time{}), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.current) != 1 || !bundle.current[0].Equal(newCA.cert) {
+		t.Errorf("current = %v, want the new CA", bundle.current)
+	}
+	if len(bundle.previous) != 1 || !bundle.previous[0].Equal(oldCA.cert) {
+		t.Errorf("previous = %v, want the old CA", bundle.previous)
+	}
+	if !bundle.rotatedAt.Equal(now) {
+		t.Errorf("rotatedAt = %v, want %v", bundle.rotatedAt, now)
+	}
+	published, err := parseCertificates(bundle.pem())
+	if err != nil || len(published) != 2 || !published[0].Equal(newCA.cert) {
+		t.Errorf("bundle = %v, %v, want the new CA first and the old CA", published, err)
+	}
+
+	// Within the overlap window the rotation keeps its start and both CAs
+	rotatedAt := now.Truncate(time.Second)
+	later := now.Add(time.Hour)
+	bundle, err = nextTrustBundle(certsPEM(newCA.cert), bundleSecret(bundle.pem(), rotatedAt), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.previous) != 1 || !bundle.rotatedAt.Equal(rotatedAt) {
+		t.Errorf("previous = %v, rotatedAt = %v, want the old CA since %v", bundle.previous, bundle.rotatedAt, rotatedAt)
+	}
+	if bundle.retirable(overlap, later) {
+		t.Error("retirable within the overlap window")
+	}
+	if !bundle.retirable(overlap, rotatedAt.Add(overlap)) {
+		t.Error("not retirable once the overlap window has passed")
+	}
+
+	// After the retirement only the new CA is published
+	bundle, err = nextTrustBundle(certsPEM(newCA.cert), bundleSecret(certsPEM(newCA.cert), time.Time{}), later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.previous) != 0 || !bundle.rotatedAt.IsZero() {
+		t.Errorf("previous = %v, rotatedAt = %v after the retirement", bundle.previous, bundle.rotatedAt)
+	}
+}
+
+func TestNextTrustBundleSecondRotationRestartsWindow(t *testing.T) {
+	now := time.Now()
+	first := newTestCA(t, "first", now.Add(24*time.Hour))
+	second := newTestCA(t, "second", now.Add(48*time.Hour))
+	third := newTestCA(t, "third", now.Add(72*time.Hour))
+	rotatedAt := now.Add(-time.Hour).Truncate(time.Second)
+
+	// A new CA during an overlap window restarts it
+	published := bundleSecret(certsPEM(second.cert, first.cert), rotatedAt)
+	bundle, err := nextTrustBundle(certsPEM(third.cert), published, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.previous) != 2 || !bundle.rotatedAt.Equal(now) {
+		t.Errorf("previous = %v, rotatedAt = %v, want both older CAs since %v", bundle.previous, bundle.rotatedAt, now)
+	}
+}
+
+func TestNextTrustBundleDropsExpiredPrevious(t *testing.T) {
+	now := time.Now()
+	oldCA := newTestCA(t, "old", now.Add(-time.Minute))
+	newCA := newTestCA(t, "new", now.Add(48*time.Hour))
+
+	bundle, err := nextTrustBundle(certsPEM(newCA.cert), bundleSecret(certsPEM(newCA.cert, oldCA.cert), now.Add(-time.Hour)), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundle.previous) != 0 || !bundle.rotatedAt.IsZero() {
+		t.Errorf("previous = %v, rotatedAt = %v, want the expired CA dropped", bundle.previous, bundle.rotatedAt)
+	}
+}
+
+func TestIssuedUnder(t *testing.T) {
+	now := time.Now()
+	oldCA := newTestCA(t, "old", now.Add(24*time.Hour))
+	newCA := newTestCA(t, "new", now.Add(48*time.Hour))
+	leaf := oldCA.leafPEM(t)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		roots []*x509.Certificate
+		want  bool
+	}{
+		{name: "signing CA", data: leaf, roots: []*x509.Certificate{oldCA.cert}, want: true},
+		{name: "other CA", data: leaf, roots: []*x509.Certificate{newCA.cert}, want: false},
+		{name: "overlapping bundle", data: leaf, roots: []*x509.Certificate{newCA.cert, oldCA.cert}, want: true},
+		{name: "reissued leaf", data: newCA.leafPEM(t), roots: []*x509.Certificate{newCA.cert}, want: true},
+		{name: "no certificate", data: nil, roots: []*x509.Certificate{oldCA.cert}, want: false},
+		{name: "garbage", data: []byte("not a certificate"), roots: []*x509.Certificate{oldCA.cert}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issuedUnder(tt.data, tt.roots); got != tt.want {
+				t.Errorf("issuedUnder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLeafPendingIssuerSwitch(t *testing.T) {
+	now := time.Now()
+	oldCA := newTestCA(t, "old", now.Add(24*time.Hour))
+	newCA := newTestCA(t, "new", now.Add(48*time.Hour))
+	oldIssuer := certmanagermetav1.ObjectReference{Name: "auto-mtls-cluster-ca-issuer", Kind: "ClusterIssuer"}
+	newIssuer := certmanagermetav1.ObjectReference{Name: "corporate-ca", Kind: "ClusterIssuer", Group: "cert-manager.io"}
+	current := []*x509.Certificate{newCA.cert}
+
+	tests := []struct {
+		name   string
+		issuer certmanagermetav1.ObjectReference
+		leaf   []byte
+		want   bool
+	}{
+		// The leaf still chains to the old CA, and its Secret is not even read
+		{name: "still on the previous issuer", issuer: oldIssuer, leaf: nil, want: true},
+		{name: "moved but not reissued yet", issuer: newIssuer, leaf: oldCA.leafPEM(t), want: true},
+		{name: "reissued under the new CA", issuer: newIssuer, leaf: newCA.leafPEM(t), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &certmanagerv1.Certificate{Spec: certmanagerv1.CertificateSpec{IssuerRef: tt.issuer}}
+			if got := leafPending(cert, tt.leaf, newIssuer, current); got != tt.want {
+				t.Errorf("leafPending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// errImmutableTemplate is returned when a workload needs new mounts but its pod
+// template cannot be changed, as is the case for Jobs.
+var errImmutableTemplate = errors.New("pod template is immutable")
+
+// workload is a pod-template-bearing object the operator mounts certificates into.
+type workload struct {
+	kind string
+	client.Object
+}
+
+// String returns "<Kind>/<name>".
+func (w workload) String() string {
+	return w.kind + "/" + w.GetName()
+}
+
+// podTemplate returns the pod template of a workload object, or nil for other types.
+func podTemplate(obj client.Object) *corev1.PodTemplateSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template
+	case *appsv1.ReplicaSet:
+		return &o.Spec.Template
+	case *batchv1.Job:
+		return &o.Spec.Template
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template
+	}
+	return nil
+}
+
+// workloadKind returns the kind of a workload object, or "" for other types.
+func workloadKind(obj client.Object) string {
+	switch obj.(type) {
+	case *appsv1.Deployment:
+		return "Deployment"
+	case *appsv1.StatefulSet:
+		return "StatefulSet"
+	case *appsv1.DaemonSet:
+		return "DaemonSet"
+	case *appsv1.ReplicaSet:
+		return "ReplicaSet"
+	case *batchv1.Job:
+		return "Job"
+	case *batchv1.CronJob:
+		return "CronJob"
+	}
+	return ""
+}
+
+// newWorkloadObject returns an empty object of the given workload kind, or nil for
+// other kinds.
+func newWorkloadObject(kind string) client.Object {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	case "ReplicaSet":
+		return &appsv1.ReplicaSet{}
+	case "Job":
+		return &batchv1.Job{}
+	case "CronJob":
+		return &batchv1.CronJob{}
+	}
+	return nil
+}
+
+// listWorkloads lists the workloads of every supported kind in namespace. ReplicaSets
+// owned by a Deployment and Jobs owned by a CronJob are skipped, their owner carries
+// the pod template that has to be patched.
+func listWorkloads(ctx context.Context, c client.Reader, namespace string) ([]workload, error) {
+	var workloads []workload
+
+	var deployList appsv1.DeploymentList
+	if err := c.List(ctx, &deployList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range deployList.Items {
+		workloads = append(workloads, workload{"Deployment", &deployList.Items[i]})
+	}
+
+	var stsList appsv1.StatefulSetList
+	if err := c.List(ctx, &stsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range stsList.Items {
+		workloads = append(workloads, workload{"StatefulSet", &stsList.Items[i]})
+	}
+
+	var dsList appsv1.DaemonSetList
+	if err := c.List(ctx, &dsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range dsList.Items {
+		workloads = append(workloads, workload{"DaemonSet", &dsList.Items[i]})
+	}
+
+	var rsList appsv1.ReplicaSetList
+	if err := c.List(ctx, &rsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range rsList.Items {
+		if !ownedBy(&rsList.Items[i], "Deployment") {
+			workloads = append(workloads, workload{"ReplicaSet", &rsList.Items[i]})
+		}
+	}
+
+	var jobList batchv1.JobList
+	if err := c.List(ctx, &jobList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range jobList.Items {
+		if !ownedBy(&jobList.Items[i], "CronJob") {
+			workloads = append(workloads, workload{"Job", &jobList.Items[i]})
+		}
+	}
+
+	var cronJobList batchv1.CronJobList
+	if err := c.List(ctx, &cronJobList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range cronJobList.Items {
+		workloads = append(workloads, workload{"CronJob", &cronJobList.Items[i]})
+	}
+
+	return workloads, nil
+}
+
+// ownedBy reports whether obj has a controller owner of the given kind.
+func ownedBy(obj client.Object, kind string) bool {
+	owner := metav1.GetControllerOf(obj)
+	return owner != nil && owner.Kind == kind
+}
+
+// patchPodTemplate applies mutate to a copy of the workload pod template and patches
+// the workload when mutate reports a change.
+func patchPodTemplate(ctx context.Context, c client.Client, w workload,
+	mutate func(*corev1.PodTemplateSpec) (bool, error)) (bool, error) {
+	patched := w.DeepCopyObject().(client.Object)
+
+	changed, err := mutate(podTemplate(patched))
+	if err != nil || !changed {
+		return false, err
+	}
+	if w.kind == "Job" {
+		return false, errImmutableTemplate
+	}
+	return true, c.Patch(ctx, patched, client.MergeFrom(w.Object))
+}
+
+// serviceSelectorIndex indexes Services by the "<key>=<value>" pairs of their selector.
+const serviceSelectorIndex = "spec.selector"
+
+// indexServiceSelector is the indexer func of serviceSelectorIndex.
+func indexServiceSelector(obj client.Object) []string {
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return nil
+	}
+	pairs := make([]string, 0, len(svc.Spec.Selector))
+	for k, v := range svc.Spec.Selector {
+		pairs = append(pairs, k+"="+v)
+	}
+	return pairs
+}
+
+// servicesForWorkload maps a workload to the mTLS-enabled Services selecting its pod
+// template, so workloads created or changed after their Service get the mounts.
+func (r *AutomtlsReconciler) servicesForWorkload(ctx context.Context, obj client.Object) []reconcile.Request {
+	template := podTemplate(obj)
+	if template == nil || ownedBy(obj, "Deployment") || ownedBy(obj, "CronJob") {
+		return nil
+	}
+
+	seen := map[types.NamespacedName]bool{}
+	var requests []reconcile.Request
+	for k, v := range template.Labels {
+		var svcList corev1.ServiceList
+		if err := r.List(ctx, &svcList, client.InNamespace(obj.GetNamespace()),
+			client.MatchingFields{serviceSelectorIndex: k + "=" + v}); err != nil {
+			return nil
+		}
+		for i := range svcList.Items {
+			svc := &svcList.Items[i]
+			key := client.ObjectKeyFromObject(svc)
+			if seen[key] || !selectorMatches(template.Labels, svc.Spec.Selector) || !r.managesService(ctx, svc) {
+				continue
+			}
+			seen[key] = true
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+	}
+
+	// A workload the Service no longer selects keeps its mounts until the Service
+	// removes them
+	for _, name := range mountedServices(template) {
+		key := types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}
+		if !seen[key] {
+			seen[key] = true
+			requests = append(requests, reconcile.Request{NamespacedName: key})
+		}
+	}
+	return requests
+}
+
+// mountedServices returns the names of the Services whose certificate volume or
+// secret hash is on template.
+func mountedServices(template *corev1.PodTemplateSpec) []string {
+	var names []string
+	for key := range template.Annotations {
+		if name, ok := strings.CutPrefix(key, serviceHashAnnotationPrefix); ok {
+			names = append(names, name)
+		}
+	}
+	for _, v := range template.Spec.Volumes {
+		name, ok := strings.CutSuffix(v.Name, "-cert-tls")
+		if ok && hasSecretVolume(&template.Spec, v.Name) && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kupher-tools/auto-mtls/internal/controller"
+)
+
+// podlog is for logging in this package.
+var podlog = logf.Log.WithName("pod-resource")
+
+// SetupPodWebhookWithManager registers the webhook for Pod in the manager.
+func SetupPodWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&corev1.Pod{}).
+		WithDefaulter(&PodCustomDefaulter{Reader: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate--v1-pod,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=pods,verbs=create,versions=v1,name=mpod-v1.kb.io,admissionReviewVersions=v1,reinvocationPolicy=IfNeeded
+
+// PodCustomDefaulter injects the mTLS certificate and CA volumes into Pods selected
+// by an mTLS-enabled Service when they are created.
+type PodCustomDefaulter struct {
+	Reader client.Reader
+}
+
+var _ admission.CustomDefaulter = &PodCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind Pod.
+func (d *PodCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return fmt.Errorf("expected an Pod object but got %T", obj)
+	}
+
+	// Pods created by controllers often have no namespace set yet
+	namespace := pod.Namespace
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Namespace != "" {
+		namespace = req.Namespace
+	}
+
+	sources, err := controller.InjectPodMounts(ctx, d.Reader, namespace, pod)
+	if err != nil {
+		// Admit the Pod anyway, like failurePolicy=ignore does when the webhook is down
+		podlog.Error(err, "Failed to inject mTLS mounts into pod", "namespace", namespace,
+			"name", pod.Name, "generateName", pod.GenerateName)
+		return nil
+	}
+	if len(sources) > 0 {
+		podlog.Info("Injected mTLS mounts into pod", "namespace", namespace,
+			"name", pod.Name, "generateName", pod.GenerateName, "sources", sources)
+	}
+	return nil
+}
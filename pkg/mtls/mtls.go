@@ -0,0 +1,237 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mtls builds TLS configurations from the certificates auto-mtls mounts into
+// workloads and keeps them current. The files are polled and a renewed certificate or
+// CA bundle is swapped in atomically, so servers and clients pick up cert-manager
+// renewals and CA rotations without a restart.
+//
+// It only depends on the standard library:
+//
+//	source, err := mtls.NewSource(mtls.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go source.Run(ctx)
+//	server := &http.Server{Addr: ":8443", TLSConfig: source.ServerConfig()}
+//	log.Fatal(server.ListenAndServeTLS("", ""))
+package mtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// File layout of the auto-mtls mounts.
+const (
+	// DefaultCertDir is where the certificate and key are mounted by default.
+	DefaultCertDir = "/etc/tls"
+	// DefaultCADir is where the CA bundle is mounted by default.
+	DefaultCADir = "/etc/ca"
+
+	CertFile = "tls.crt"
+	KeyFile  = "tls.key"
+	CAFile   = "ca.crt"
+)
+
+// DefaultPollInterval is how often the files are checked for changes by default.
+// Kubelet refreshes mounted Secrets about once a minute.
+const DefaultPollInterval = 10 * time.Second
+
+// Options configures a Source. The zero value uses the auto-mtls defaults.
+type Options struct {
+	// CertDir holds tls.crt and tls.key. Defaults to DefaultCertDir.
+	CertDir string
+	// CADir holds ca.crt. Defaults to DefaultCADir.
+	CADir string
+	// PollInterval is how often the files are checked. Defaults to DefaultPollInterval.
+	PollInterval time.Duration
+	// OnError is called when a reload fails, the previous material stays in use.
+	// Defaults to logging the error.
+	OnError func(error)
+}
+
+// material is a consistent set of certificate, key and CA pool.
+type material struct {
+	certPEM, keyPEM, caPEM []byte
+	cert                   *tls.Certificate
+	roots                  *x509.CertPool
+}
+
+// Source holds the current mTLS material and reloads it when the files change.
+type Source struct {
+	opts    Options
+	current atomic.Pointer[material]
+}
+
+// NewSource loads the certificate, key and CA bundle. It fails if any of them cannot
+// be loaded, which usually means the mounts are missing.
+func NewSource(opts Options) (*Source, error) {
+	if opts.CertDir == "" {
+		opts.CertDir = DefaultCertDir
+	}
+	if opts.CADir == "" {
+		opts.CADir = DefaultCADir
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) { log.Printf("mtls: %v", err) }
+	}
+
+	s := &Source{opts: opts}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Run polls the files until ctx is done, reloading them when they change.
+func (s *Source) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reload(); err != nil {
+				s.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// Reload reads the files and swaps in the new material if it changed. It reports
+// whether it did. Material that does not load, for example a key that does not match
+// the certificate, is rejected and the previous material stays in use.
+func (s *Source) Reload() (bool, error) {
+	certPEM, err := os.ReadFile(filepath.Join(s.opts.CertDir, CertFile))
+	if err != nil {
+		return false, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(s.opts.CertDir, KeyFile))
+	if err != nil {
+		return false, err
+	}
+	caPEM, err := os.ReadFile(filepath.Join(s.opts.CADir, CAFile))
+	if err != nil {
+		return false, err
+	}
+
+	if old := s.current.Load(); old != nil && bytes.Equal(old.certPEM, certPEM) &&
+		bytes.Equal(old.keyPEM, keyPEM) && bytes.Equal(old.caPEM, caPEM) {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("loading key pair from %s: %w", s.opts.CertDir, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return false, fmt.Errorf("no CA certificates in %s", filepath.Join(s.opts.CADir, CAFile))
+	}
+
+	s.current.Store(&material{
+		certPEM: certPEM,
+		keyPEM:  keyPEM,
+		caPEM:   caPEM,
+		cert:    &cert,
+		roots:   roots,
+	})
+	return true, nil
+}
+
+// Certificate returns the current certificate.
+func (s *Source) Certificate() *tls.Certificate {
+	return s.current.Load().cert
+}
+
+// ServerConfig returns a server configuration that presents the current certificate
+// and requires client certificates issued under the current CA bundle.
+func (s *Source) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.Certificate(), nil
+		},
+		// ClientCAs cannot be swapped, the chain is verified in VerifyConnection
+		ClientAuth: tls.RequireAnyClientCert,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return s.verify(cs.PeerCertificates, "", x509.ExtKeyUsageClientAuth)
+		},
+	}
+}
+
+// ClientConfig returns a client configuration that presents the current certificate
+// and verifies the server certificate and name against the current CA bundle.
+// Servers must be dialed by name, auto-mtls certificates carry no IP addresses.
+func (s *Source) ClientConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return s.Certificate(), nil
+		},
+		// RootCAs cannot be swapped, the chain and name are verified in VerifyConnection
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				return errors.New("mtls: no server name to verify the server certificate against")
+			}
+			return s.verify(cs.PeerCertificates, cs.ServerName, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
+
+// verify checks that chain leads to the current CA bundle for usage and, if name is
+// not empty, that the leaf is valid for name.
+func (s *Source) verify(chain []*x509.Certificate, name string, usage x509.ExtKeyUsage) error {
+	if len(chain) == 0 {
+		return errors.New("mtls: no peer certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         s.current.Load().roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+// SPIFFEID returns the SPIFFE ID in the URI SANs of cert, or "" if it has none.
+func SPIFFEID(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// issue returns the PEM encoded certificate and key of a leaf for dnsName with the
+// given usages and SPIFFE ID.
+func (ca testCA) issue(t *testing.T, dnsName, spiffeID string, usages ...x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  usages,
+	}
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+// leaf parses the certificate in certPEM.
+func leaf(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeMounts lays out the files the way the operator mounts them.
+func writeMounts(t *testing.T, opts Options, certPEM, keyPEM, caPEM []byte) {
+	t.Helper()
+	for path, data := range map[string][]byte{
+		filepath.Join(opts.CertDir, CertFile): certPEM,
+		filepath.Join(opts.CertDir, KeyFile):  keyPEM,
+		filepath.Join(opts.CADir, CAFile):     caPEM,
+	} {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testOptions(t *testing.T) Options {
+	return Options{CertDir: t.TempDir(), CADir: t.TempDir(), OnError: func(err error) { t.Log(err) }}
+}
+
+func TestNewSourceMissingFiles(t *testing.T) {
+	if _, err := NewSource(testOptions(t)); err == nil {
+		t.Error("no error without mounted files")
+	}
+}
+
+func TestReloadUnchanged(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	opts := testOptions(t)
+	certPEM, keyPEM := ca.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, certPEM, keyPEM, ca.pem)
+
+	source, err := NewSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := source.Certificate()
+	changed, err := source.Reload()
+	if err != nil || changed {
+		t.Errorf("Reload() = %v, %v, want false, nil for unchanged files", changed, err)
+	}
+	if source.Certificate() != before {
+		t.Error("certificate swapped although the files did not change")
+	}
+}
+
+func TestReloadRejectsMismatchedKey(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	opts := testOptions(t)
+	certPEM, keyPEM := ca.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, certPEM, keyPEM, ca.pem)
+	source, err := NewSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := source.Certificate()
+
+	// kubelet has written the renewed certificate but not its key yet
+	renewedPEM, _ := ca.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, renewedPEM, keyPEM, ca.pem)
+	changed, err := source.Reload()
+	if err == nil || changed {
+		t.Errorf("Reload() = %v, %v, want an error for a mismatched key", changed, err)
+	}
+	if source.Certificate() != before {
+		t.Error("mismatched key pair was swapped in")
+	}
+}
+
+func TestReloadRejectsEmptyCA(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	opts := testOptions(t)
+	certPEM, keyPEM := ca.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, certPEM, keyPEM, ca.pem)
+	source, err := NewSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeMounts(t, opts, certPEM, keyPEM, []byte("not a certificate"))
+	if changed, err := source.Reload(); err == nil || changed {
+		t.Errorf("Reload() = %v, %v, want an error for a CA file without certificates", changed, err)
+	}
+	peerPEM, _ := ca.issue(t, "client", "", x509.ExtKeyUsageClientAuth)
+	if err := source.verify([]*x509.Certificate{leaf(t, peerPEM)}, "", x509.ExtKeyUsageClientAuth); err != nil {
+		t.Errorf("previous CA no longer trusted: %v", err)
+	}
+}
+
+func TestReloadSwapsCA(t *testing.T) {
+	oldCA := newTestCA(t, "old")
+	newCA := newTestCA(t, "new")
+	opts := testOptions(t)
+	certPEM, keyPEM := oldCA.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, certPEM, keyPEM, oldCA.pem)
+	source, err := NewSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPeerPEM, _ := oldCA.issue(t, "client", "", x509.ExtKeyUsageClientAuth)
+	newPeerPEM, _ := newCA.issue(t, "client", "", x509.ExtKeyUsageClientAuth)
+	oldPeer := []*x509.Certificate{leaf(t, oldPeerPEM)}
+	newPeer := []*x509.Certificate{leaf(t, newPeerPEM)}
+	if err := source.verify(newPeer, "", x509.ExtKeyUsageClientAuth); err == nil {
+		t.Fatal("peer of the new CA trusted before the rotation")
+	}
+
+	// During the rotation the bundle holds both CAs
+	writeMounts(t, opts, certPEM, keyPEM, append(append([]byte{}, newCA.pem...), oldCA.pem...))
+	if changed, err := source.Reload(); err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v, want true, nil for a new CA bundle", changed, err)
+	}
+	for name, peer := range map[string][]*x509.Certificate{"old": oldPeer, "new": newPeer} {
+		if err := source.verify(peer, "", x509.ExtKeyUsageClientAuth); err != nil {
+			t.Errorf("peer of the %s CA not trusted during the rotation: %v", name, err)
+		}
+	}
+
+	// Afterwards the old CA is gone
+	writeMounts(t, opts, certPEM, keyPEM, newCA.pem)
+	if changed, err := source.Reload(); err != nil || !changed {
+		t.Fatalf("Reload() = %v, %v, want true, nil for a new CA bundle", changed, err)
+	}
+	if err := source.verify(oldPeer, "", x509.ExtKeyUsageClientAuth); err == nil {
+		t.Error("peer of the retired CA still trusted")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	other := newTestCA(t, "other")
+	opts := testOptions(t)
+	certPEM, keyPEM := ca.issue(t, "svc.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	writeMounts(t, opts, certPEM, keyPEM, ca.pem)
+	source, err := NewSource(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverPEM, _ := ca.issue(t, "server.ns.svc", "", x509.ExtKeyUsageServerAuth)
+	clientPEM, _ := ca.issue(t, "client.ns.svc", "", x509.ExtKeyUsageClientAuth)
+	untrustedPEM, _ := other.issue(t, "server.ns.svc", "", x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+
+	tests := []struct {
+		name    string
+		chain   []*x509.Certificate
+		dnsName string
+		usage   x509.ExtKeyUsage
+		wantErr bool
+	}{
+		{name: "server", chain: []*x509.Certificate{leaf(t, serverPEM)}, dnsName: "server.ns.svc", usage: x509.ExtKeyUsageServerAuth},
+		{name: "client", chain: []*x509.Certificate{leaf(t, clientPEM)}, usage: x509.ExtKeyUsageClientAuth},
+		{name: "server certificate as client", chain: []*x509.Certificate{leaf(t, serverPEM)},
+			usage: x509.ExtKeyUsageClientAuth, wantErr: true},
+		{name: "client certificate as server", chain: []*x509.Certificate{leaf(t, clientPEM)},
+			dnsName: "client.ns.svc", usage: x509.ExtKeyUsageServerAuth, wantErr: true},
+		{name: "wrong name", chain: []*x509.Certificate{leaf(t, serverPEM)}, dnsName: "other.ns.svc",
+			usage: x509.ExtKeyUsageServerAuth, wantErr: true},
+		{name: "untrusted root", chain: []*x509.Certificate{leaf(t, untrustedPEM)}, dnsName: "server.ns.svc",
+			usage: x509.ExtKeyUsageServerAuth, wantErr: true},
+		{name: "untrusted root as client", chain: []*x509.Certificate{leaf(t, untrustedPEM)},
+			usage: x509.ExtKeyUsageClientAuth, wantErr: true},
+		{name: "no certificate", usage: x509.ExtKeyUsageClientAuth, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := source.verify(tt.chain, tt.dnsName, tt.usage)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	serverOpts, clientOpts := testOptions(t), testOptions(t)
+	certPEM, keyPEM := ca.issue(t, "server.ns.svc", "spiffe://cluster.local/ns/ns/sa/server",
+		x509.ExtKeyUsageServerAuth)
+	writeMounts(t, serverOpts, certPEM, keyPEM, ca.pem)
+	certPEM, keyPEM = ca.issue(t, "client.ns.svc", "spiffe://cluster.local/ns/ns/sa/client",
+		x509.ExtKeyUsageClientAuth)
+	writeMounts(t, clientOpts, certPEM, keyPEM, ca.pem)
+
+	server, err := NewSource(serverOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSource(clientOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handshake := func(serverName string) (tls.ConnectionState, error) {
+		serverConn, clientConn := net.Pipe()
+		defer serverConn.Close()
+		defer clientConn.Close()
+		serverTLS := tls.Server(serverConn, server.ServerConfig())
+		clientConfig := client.ClientConfig()
+		clientConfig.ServerName = serverName
+		clientTLS := tls.Client(clientConn, clientConfig)
+
+		done := make(chan tls.ConnectionState, 1)
+		go func() {
+			if serverTLS.Handshake() != nil {
+				clientConn.Close()
+			}
+			done <- serverTLS.ConnectionState()
+		}()
+		err := clientTLS.Handshake()
+		if err != nil {
+			serverConn.Close()
+		}
+		return <-done, err
+	}
+
+	state, err := handshake("server.ns.svc")
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if id := SPIFFEID(state.PeerCertificates[0]); id != "spiffe://cluster.local/ns/ns/sa/client" {
+		t.Errorf("client SPIFFE ID = %q", id)
+	}
+	if _, err := handshake("other.ns.svc"); err == nil {
+		t.Error("handshake succeeded with the wrong server name")
+	}
+	if _, err := handshake(""); err == nil {
+		t.Error("handshake succeeded without a server name")
+	}
+}
+
+func TestSPIFFEID(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	withID, _ := ca.issue(t, "svc", "spiffe://cluster.local/ns/ns/sa/api", x509.ExtKeyUsageClientAuth)
+	withoutID, _ := ca.issue(t, "svc", "", x509.ExtKeyUsageClientAuth)
+	if id := SPIFFEID(leaf(t, withID)); id != "spiffe://cluster.local/ns/ns/sa/api" {
+		t.Errorf("SPIFFEID() = %q", id)
+	}
+	if id := SPIFFEID(leaf(t, withoutID)); id != "" {
+		t.Errorf("SPIFFEID() = %q, want none", id)
+	}
+}